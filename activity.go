@@ -0,0 +1,171 @@
+package cella
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// ActivityTracker is an observer that keeps two layers of values per cell,
+// indexed as [y][x] like the cells of a grid
+type ActivityTracker struct {
+	Activity    [][]int // Number of times each cell changed state
+	Age         [][]int // Generations since the last change of each cell
+	Generations int     // Generations tracked
+}
+
+// TrackActivity creates an activity tracker starting in the current
+// generation and adds it as an observer of the automaton
+func (c *Cella2d) TrackActivity() *ActivityTracker {
+	t := &ActivityTracker{Activity: newLayer(c.Width, c.Height), Age: newLayer(c.Width, c.Height)}
+	c.AddObserver(t)
+	return t
+}
+
+// newLayer creates a layer of values for each cell
+func newLayer(width, height int) [][]int {
+	l := make([][]int, height)
+	for y := range l {
+		l[y] = make([]int, width)
+	}
+	return l
+}
+
+// OnStep updates the layers with the cells that changed
+func (t *ActivityTracker) OnStep(c *Cella2d) {
+	t.Generations++
+	for y, row := range c.InitGrid.Cells {
+		prev := c.NextGrid.Cells[y]
+		for x, cell := range row {
+			if cell != prev[x] {
+				t.Activity[y][x]++
+				t.Age[y][x] = 0
+			} else {
+				t.Age[y][x]++
+			}
+		}
+	}
+}
+
+// OnRuleError does nothing, the generation was not calculated
+func (t *ActivityTracker) OnRuleError(c *Cella2d, err error) {}
+
+// OnStop does nothing, the tracker keeps tracking if the automaton runs again
+func (t *ActivityTracker) OnStop(c *Cella2d) {}
+
+// Reset sets both layers to 0
+func (t *ActivityTracker) Reset() {
+	for y := range t.Activity {
+		for x := range t.Activity[y] {
+			t.Activity[y][x] = 0
+			t.Age[y][x] = 0
+		}
+	}
+	t.Generations = 0
+}
+
+// layerMax returns the greatest value of the layer
+func layerMax(values [][]int) int {
+	m := 0
+	for _, row := range values {
+		for _, v := range row {
+			if v > m {
+				m = v
+			}
+		}
+	}
+	return m
+}
+
+// QuantizeLayer converts a layer of values to a grid with the given number
+// of levels, from 2 to 256. Values are scaled linearly so the greatest value
+// is the last level, negative values are level 0.
+func QuantizeLayer(values [][]int, levels int) *Grid {
+	if len(values) == 0 || len(values[0]) == 0 {
+		return nil
+	}
+	if levels < 2 {
+		levels = 2
+	}
+	if levels > 256 {
+		levels = 256
+	}
+	g := NewGrid(len(values[0]), len(values))
+	max := layerMax(values)
+	if max == 0 {
+		return g
+	}
+	for y, row := range values {
+		for x, v := range row {
+			if v <= 0 {
+				continue
+			}
+			// Values greater than 0 are never level 0
+			l := levels - 1
+			if max > 1 {
+				l = 1 + (v-1)*(levels-2)/(max-1)
+			}
+			g.Cells[y][x] = Cell(l)
+		}
+	}
+	return g
+}
+
+// HeatPalette creates a palette of the given number of levels going from
+// black through red and yellow to white
+func HeatPalette(levels int) Palette {
+	p := make(Palette, levels)
+	for i := range p {
+		t := 0.0
+		if levels > 1 {
+			t = float64(i) / float64(levels-1)
+		}
+		// Each channel rises in its own third of the gradient
+		ch := func(start float64) uint8 {
+			v := (t - start) * 3
+			if v < 0 {
+				v = 0
+			}
+			if v > 1 {
+				v = 1
+			}
+			return uint8(255 * v)
+		}
+		p[i] = color.RGBA{ch(0), ch(1.0 / 3), ch(2.0 / 3), 0xff}
+	}
+	return p
+}
+
+// RenderLayer renders a layer of values as a heatmap. The values are
+// quantized to the levels of the palette, a heat palette of 256 levels
+// if it is not set, or 255 with grid lines to leave a color for them.
+func RenderLayer(values [][]int, opts RenderOptions) (*image.Paletted, error) {
+	if opts.Palette == nil {
+		levels := 256
+		if opts.GridLines {
+			levels--
+		}
+		opts.Palette = HeatPalette(levels)
+	}
+	g := QuantizeLayer(values, len(opts.Palette))
+	if g == nil {
+		return nil, fmt.Errorf("render: empty layer")
+	}
+	cp, err := opts.palette(len(opts.Palette), false)
+	if err != nil {
+		return nil, err
+	}
+	return renderGrid(g, &opts, cp, len(opts.Palette)), nil
+}
+
+// EncodeLayerPNG renders a layer of values as a heatmap and writes it as
+// a PNG image
+func EncodeLayerPNG(w io.Writer, values [][]int, opts RenderOptions) error {
+	img, err := RenderLayer(values, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,42 @@
+package cella
+
+import (
+	"testing"
+)
+
+func TestActivityTracker(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	tr := ca.TrackActivity()
+	if err := ca.Run(4); err != nil {
+		t.Fatal(err)
+	}
+	// The ends of the blinker change every generation, the center never does
+	if tr.Activity[2][1] != 4 || tr.Activity[1][2] != 4 || tr.Activity[2][2] != 0 || tr.Activity[0][0] != 0 {
+		t.Fatalf("Activity does not match: %v", tr.Activity)
+	}
+	if tr.Age[2][1] != 0 || tr.Age[2][2] != 4 || tr.Generations != 4 {
+		t.Fatalf("Age does not match: %v", tr.Age)
+	}
+
+	g := QuantizeLayer(tr.Activity, 3)
+	if g.GetCell(2, 1) != 2 || g.GetCell(2, 2) != 0 {
+		t.Fatal("Quantized layer does not match")
+	}
+	img, err := RenderLayer(tr.Age, RenderOptions{CellSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 10 || img.ColorIndexAt(4, 4) != 255 || img.ColorIndexAt(2, 4) != 0 {
+		t.Fatal("Rendered layer does not match")
+	}
+	img, err = RenderLayer(tr.Age, RenderOptions{GridLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ColorIndexAt(0, 0) != 255 || img.ColorIndexAt(5, 5) != 254 {
+		t.Fatal("Rendered layer with grid lines does not match")
+	}
+}
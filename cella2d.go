@@ -1,5 +1,16 @@
 package cella
 
+import "fmt"
+
+// Boundary defines how the auxiliar borders of the grid are filled
+// before each generation is calculated
+type Boundary uint8
+
+const (
+	BoundaryFixed    Boundary = iota // Auxiliar borders keep the values set by the user
+	BoundaryToroidal                 // Auxiliar borders wrap around as in a toroidal grid
+)
+
 // Cellular Automaton 2D.
 // Currently only supports 3x3 neighbourhoods.
 type Cella2d struct {
@@ -11,7 +22,11 @@ type Cella2d struct {
 	NumStates     int       // Number of states of the automaton
 	States        []Cell    // States of the automaton
 	CellsPerState []int     // Number of cells per state
+	Transitions   [][]int   // Number of cells that went from state i to state j, as [i][j], in the last generation
 	Generation    int       // Generation of the automaton
+	Boundary      Boundary  // Boundary of the grid
+	observers     []Observer
+	ruleStats     *RuleStats
 }
 
 // NewCella2d creates a new cellular automaton 2D
@@ -25,12 +40,47 @@ func NewCella2d(Width, Height, numStates int) *Cella2d {
 	c.InitGrid = nil
 	c.NextGrid = nil
 	c.Generation = 0
+	c.Boundary = BoundaryFixed
 	c.NumStates = numStates
 	c.States = make([]Cell, numStates)
 	c.CellsPerState = make([]int, numStates)
+	c.Transitions = newTransitions(numStates)
 	return c
 }
 
+// newTransitions creates a matrix of transitions between the states
+func newTransitions(numStates int) [][]int {
+	t := make([][]int, numStates)
+	for i := range t {
+		t[i] = make([]int, numStates)
+	}
+	return t
+}
+
+// Clone returns a copy of the automaton with its own grids and counts.
+// The rules are shared with the original automaton, so both must not run
+// at the same time in different goroutines. Observers and rule tracking
+// are not copied.
+func (c *Cella2d) Clone() *Cella2d {
+	cp := NewCella2d(c.Width, c.Height, c.NumStates)
+	cp.SetRules(append([]*Rule2d(nil), c.Rules...))
+	cp.SetBoundary(c.Boundary)
+	cp.SetGeneration(c.Generation)
+	cp.SetCellsPerState(c.CellsPerState)
+	for i := range c.Transitions {
+		if i < len(cp.Transitions) {
+			copy(cp.Transitions[i], c.Transitions[i])
+		}
+	}
+	if c.InitGrid != nil {
+		cp.SetInitGrid(c.InitGrid.Clone())
+	}
+	if c.NextGrid != nil {
+		cp.SetNextGrid(c.NextGrid.Clone())
+	}
+	return cp
+}
+
 // SetInitGrid sets the initial grid of the automaton
 func (c *Cella2d) SetInitGrid(g *Grid) {
 	c.InitGrid = g
@@ -50,6 +100,7 @@ func (c *Cella2d) SetRules(r []*Rule2d) {
 func (c *Cella2d) SetStates(numStates int) {
 	c.States = make([]Cell, numStates)
 	c.CellsPerState = make([]int, numStates)
+	c.Transitions = newTransitions(numStates)
 }
 
 // SetCellsPerState sets the number of cells per state of the automaton
@@ -62,6 +113,33 @@ func (c *Cella2d) SetGeneration(g int) {
 	c.Generation = g
 }
 
+// SetBoundary sets the boundary of the automaton
+func (c *Cella2d) SetBoundary(b Boundary) {
+	c.Boundary = b
+}
+
+// SetCell sets the state of a cell of the initial grid. An
+// OutOfBoundsError is returned if the position is outside of the grid and
+// an InvalidStateError if the automaton does not have the state.
+func (c *Cella2d) SetCell(x, y int, state Cell) error {
+	if c.InitGrid == nil {
+		return fmt.Errorf("cella2d: initial grid not set")
+	}
+	if int(state) >= c.NumStates {
+		return &InvalidStateError{X: x, Y: y, State: state, NumStates: c.NumStates}
+	}
+	return c.InitGrid.SetCellChecked(x, y, state)
+}
+
+// GetCell gets the state of a cell of the initial grid. An
+// OutOfBoundsError is returned if the position is outside of the grid.
+func (c *Cella2d) GetCell(x, y int) (Cell, error) {
+	if c.InitGrid == nil {
+		return 0, fmt.Errorf("cella2d: initial grid not set")
+	}
+	return c.InitGrid.GetCellChecked(x, y)
+}
+
 // GetInitGrid gets the initial grid of the automaton
 func (c *Cella2d) GetInitGrid() *Grid {
 	return c.InitGrid
@@ -87,11 +165,22 @@ func (c *Cella2d) GetCellsPerState() []int {
 	return c.CellsPerState
 }
 
+// GetTransitions gets the number of cells that went from state i to
+// state j in the last generation, as [i][j]
+func (c *Cella2d) GetTransitions() [][]int {
+	return c.Transitions
+}
+
 // GetGeneration gets the generation of the automaton
 func (c *Cella2d) GetGeneration() int {
 	return c.Generation
 }
 
+// GetBoundary gets the boundary of the automaton
+func (c *Cella2d) GetBoundary() Boundary {
+	return c.Boundary
+}
+
 // CountCellsPerState counts the number of cells per state of the automaton
 // using the initial grid
 func (c *Cella2d) CountCellsPerState() {
@@ -102,11 +191,54 @@ func (c *Cella2d) CountCellsPerState() {
 	for y := 0; y < c.Height; y++ {
 		for x := 0; x < c.Width; x++ {
 			state := c.InitGrid.GetCell(x, y)
-			c.CellsPerState[state]++
+			// Invalid states are reported by Validate
+			if int(state) < len(c.CellsPerState) {
+				c.CellsPerState[state]++
+			}
 		}
 	}
 }
 
+// Validate checks that the automaton can calculate the next generation:
+// both grids are set with the size of the automaton, the rules change
+// cells to states of the automaton, wrapped in a RuleError if not, and the
+// cells of the initial grid, auxiliar borders included, are in states of
+// the automaton, an InvalidStateError is returned if not.
+// NextGeneration makes the same checks while it calculates the generation.
+func (c *Cella2d) Validate() error {
+	if err := c.checkGrids(); err != nil {
+		return err
+	}
+	for i, r := range c.Rules {
+		if s := r.GetState(); int(s) >= c.NumStates {
+			return c.ruleStateError(i, -1, -1, s)
+		}
+	}
+	return c.InitGrid.CheckStates(c.NumStates)
+}
+
+// checkGrids checks that both grids are set with the size of the automaton
+func (c *Cella2d) checkGrids() error {
+	for _, g := range []*Grid{c.InitGrid, c.NextGrid} {
+		if g == nil {
+			return fmt.Errorf("cella2d: grids not set")
+		}
+		if g.Width != c.Width || g.Height != c.Height {
+			return fmt.Errorf("cella2d: %dx%d grid in a %dx%d automaton", g.Width, g.Height, c.Width, c.Height)
+		}
+	}
+	if len(c.CellsPerState) < c.NumStates {
+		return fmt.Errorf("cella2d: %d counts of cells per state for %d states", len(c.CellsPerState), c.NumStates)
+	}
+	return nil
+}
+
+// ruleStateError returns the error of a rule that changes a cell to a
+// state the automaton does not have
+func (c *Cella2d) ruleStateError(rule, x, y int, state Cell) error {
+	return c.ruleError(rule, x, y, fmt.Errorf("state %d out of range [0, %d)", state, c.NumStates))
+}
+
 // SetAuxBordersAsToroidal sets auxiliar borders with values as if the Grid of
 // cells had a toroidal shape
 func (c *Cella2d) SetAuxBordersAsToroidal() {
@@ -145,37 +277,90 @@ func (c *Cella2d) SetAuxBordersAsToroidal() {
 	c.InitGrid.SetAuxBorderRight(auxRight)
 }
 
-// NextGeneration calculates the next generation of a cell in the automaton
-func (c *Cella2d) nextGenerationCell(x, y int, neightbourhood [][]Cell) (Cell, error) {
-	for _, rule := range c.Rules {
+// NextGeneration calculates the next generation of a cell in the automaton.
+// The index of the rule applied is returned, -1 if no rule is applied.
+func (c *Cella2d) nextGenerationCell(x, y int, neightbourhood [][]Cell) (Cell, int, error) {
+	for i, rule := range c.Rules {
 		c.InitGrid.GetNeighbourhood(x, y, neightbourhood)
 		rule.SetNeighbourhood(neightbourhood)
 		condition, err := rule.CheckCondition()
 		if err != nil {
-			return 0, err
+			return 0, i, err
 		}
 		if condition {
-			return rule.GetState(), nil
+			return rule.GetState(), i, nil
 		}
 	}
 	// If no rule is applied, the cell keeps its state
-	return c.InitGrid.GetCell(x, y), nil
+	return c.InitGrid.GetCell(x, y), -1, nil
+}
+
+// ruleError wraps the error of a rule evaluated at a cell
+func (c *Cella2d) ruleError(rule, x, y int, err error) error {
+	return &RuleError{
+		Rule: rule, Condition: c.Rules[rule].GetCondition(), X: x, Y: y, Generation: c.Generation, Err: err,
+	}
+}
+
+// resetCounts sets the number of cells per state and the transitions to 0
+func (c *Cella2d) resetCounts() {
+	if len(c.Transitions) != len(c.CellsPerState) {
+		c.Transitions = newTransitions(len(c.CellsPerState))
+	}
+	for i := range c.CellsPerState {
+		c.CellsPerState[i] = 0
+		for j := range c.Transitions[i] {
+			c.Transitions[i][j] = 0
+		}
+	}
 }
 
 // NextGeneration calculates the next generation of the automaton
-// using the initial grid and the next grid
+// using the initial grid and the next grid.
+// The number of cells per state is updated with the cells of the next grid
+// and the transitions with the changes from the initial grid, both are
+// partial if a rule fails. Grids of the wrong size are rejected before any
+// cell is calculated, cells in states the automaton does not have are
+// returned as an InvalidStateError and errors of the rules as a RuleError.
 func (c *Cella2d) NextGeneration() error {
+	if err := c.checkGrids(); err != nil {
+		return err
+	}
+	if c.Boundary == BoundaryToroidal {
+		c.SetAuxBordersAsToroidal()
+	} else if err := c.InitGrid.checkBorderStates(c.NumStates); err != nil {
+		// Toroidal borders are copies of cells checked below
+		return err
+	}
+	c.resetCounts()
+	if c.ruleStats != nil {
+		c.ruleStats.begin(c)
+	}
 	neightbourhood := make([][]Cell, 3)
 	for i := 0; i < 3; i++ {
 		neightbourhood[i] = make([]Cell, 3)
 	}
 	for y := 0; y < c.Height; y++ {
 		for x := 0; x < c.Width; x++ {
-			state, err := c.nextGenerationCell(x, y, neightbourhood)
+			old := c.InitGrid.GetCell(x, y)
+			if int(old) >= c.NumStates {
+				return &InvalidStateError{X: x, Y: y, State: old, NumStates: c.NumStates}
+			}
+			state, rule, err := c.nextGenerationCell(x, y, neightbourhood)
 			if err != nil {
-				return err
+				return c.ruleError(rule, x, y, err)
+			}
+			if int(state) >= c.NumStates {
+				return c.ruleStateError(rule, x, y, state)
+			}
+			if c.ruleStats != nil {
+				if err := c.ruleStats.record(c, x, y, rule, neightbourhood); err != nil {
+					return err
+				}
 			}
 			c.NextGrid.SetCell(x, y, state)
+			c.CellsPerState[state]++
+			c.Transitions[old][state]++
 		}
 	}
 	c.Generation++
@@ -362,3 +362,21 @@ func TestGameOfLifeOscillators(t *testing.T) {
 		t.Fatalf("Game of life after two generations count: %v", ca.CellsPerState)
 	}
 }
+
+func TestIncrementalCellsPerState(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	// Blinker: two cells die, two are born and one survives
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	if err := ca.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if ca.CellsPerState[0] != 22 || ca.CellsPerState[1] != 3 {
+		t.Fatalf("Cells per state %v", ca.CellsPerState)
+	}
+	tr := ca.GetTransitions()
+	if tr[0][0] != 20 || tr[0][1] != 2 || tr[1][0] != 2 || tr[1][1] != 1 {
+		t.Fatalf("Transitions %v", tr)
+	}
+}
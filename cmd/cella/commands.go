@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/luis-ale-117/cella"
+)
+
+// runCmd steps an automaton and writes the resulting pattern
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var af automatonFlags
+	af.register(fs)
+	generations := fs.Int("n", 1, "number of generations, no limit if 0")
+	output := fs.String("o", "", "output file, the standard output if empty")
+	format := fs.String("format", "rle", "output format: rle, cells, life105, life106 or mc")
+	timeout := fs.Duration("timeout", 0, "stop after this time, no limit if 0")
+	untilEmpty := fs.Bool("until-empty", false, "stop when all the cells die")
+	steady := fs.Int("steady", 0, "stop when the population does not change for this many generations")
+	recurrence := fs.Bool("recurrence", false, "stop when the grid repeats a previous generation")
+	population := fs.String("population", "", "write the population of each generation to a .csv or .json file")
+	fs.Parse(args)
+
+	pf, err := cella.ParsePatternFormat(*format)
+	if err != nil {
+		return err
+	}
+	la, err := af.load(fs)
+	if err != nil {
+		return err
+	}
+	var rec *cella.PopulationRecorder
+	if *population != "" {
+		rec = la.automaton.RecordPopulation()
+	}
+	// An interrupt or the timeout stops the run and the last generation is written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	res, err := la.automaton.RunContext(ctx, cella.RunOptions{
+		Generations:      *generations,
+		StopWhenEmpty:    *untilEmpty,
+		SteadyFor:        *steady,
+		StopOnRecurrence: *recurrence,
+	})
+	if err != nil && res.Reason == cella.StopError {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stopped at generation %d: %s\n", res.Generation, res.Reason)
+	if rec != nil {
+		if err := writePopulation(*population, rec); err != nil {
+			return err
+		}
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := cella.SavePattern(out, la.automaton.InitGrid, pf, la.rule); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writePopulation writes the population records as CSV, or as JSON if the
+// file has the .json extension
+func writePopulation(path string, rec *cella.PopulationRecorder) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = rec.WriteJSON(f)
+	} else {
+		err = rec.WriteCSV(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderCmd renders a pattern as a PNG image or an animated GIF
+func renderCmd(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	var af automatonFlags
+	af.register(fs)
+	generations := fs.Int("n", 0, "generations to run, each one is a frame of a GIF")
+	output := fs.String("o", "", "output file, .png or .gif (required)")
+	cellSize := fs.Int("cell", 4, "size of each cell in pixels")
+	gridLines := fs.Bool("grid", false, "draw lines between cells")
+	delay := fs.Int("delay", 10, "delay between GIF frames in 100ths of a second")
+	loop := fs.Int("loop", 0, "GIF loop count: 0 loops forever, -1 plays once")
+	layer := fs.String("layer", "", "render a PNG heatmap of the cell activity or age over the generations: activity or age")
+	fs.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("the output file must be set with -o")
+	}
+	if *layer != "" && *layer != "activity" && *layer != "age" {
+		return fmt.Errorf("unknown layer %q", *layer)
+	}
+	ext := strings.ToLower(filepath.Ext(*output))
+	if ext != ".png" && ext != ".gif" {
+		return fmt.Errorf("unknown image format %q", filepath.Ext(*output))
+	}
+	if *layer != "" && ext != ".png" {
+		return fmt.Errorf("layers can only be rendered as .png")
+	}
+	la, err := af.load(fs)
+	if err != nil {
+		return err
+	}
+	opts := cella.RenderOptions{Palette: la.palette, CellSize: *cellSize, GridLines: *gridLines}
+	// The image is encoded in memory so a failed run leaves no file behind
+	var buf bytes.Buffer
+	if ext == ".gif" {
+		err = cella.EncodeGenerationsGIF(&buf, la.automaton, *generations, opts, cella.GIFOptions{Delay: *delay, LoopCount: *loop})
+	} else {
+		var tracker *cella.ActivityTracker
+		if *layer != "" {
+			tracker = la.automaton.TrackActivity()
+			opts.Palette = nil
+		}
+		err = la.automaton.Run(*generations)
+		if err == nil {
+			switch *layer {
+			case "activity":
+				err = cella.EncodeLayerPNG(&buf, tracker.Activity, opts)
+			case "age":
+				err = cella.EncodeLayerPNG(&buf, tracker.Age, opts)
+			default:
+				err = cella.EncodePNG(&buf, la.automaton.InitGrid, opts)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// convertCmd converts a pattern between formats
+func convertCmd(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := fs.String("to", "rle", "output format: rle, cells, life105, life106 or mc")
+	rule := fs.String("rule", "", "rule written in the output, the pattern rule if empty")
+	output := fs.String("o", "", "output file, the standard output if empty")
+	fs.Parse(args)
+
+	pf, err := cella.ParsePatternFormat(*format)
+	if err != nil {
+		return err
+	}
+	g, info, err := readPattern(fs)
+	if err != nil {
+		return err
+	}
+	if *rule == "" {
+		*rule = info.Rule
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := cella.SavePattern(out, g, pf, *rule); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// infoCmd shows the population, bounding box and period of a pattern
+func infoCmd(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	var af automatonFlags
+	af.register(fs)
+	maxGenerations := fs.Int("max", 1000, "generations run looking for a period, 0 to skip")
+	fs.Parse(args)
+
+	la, err := af.load(fs)
+	if err != nil {
+		return err
+	}
+	c := la.automaton
+	fmt.Printf("rule        %s\n", la.rule)
+	fmt.Printf("grid        %dx%d\n", c.Width, c.Height)
+	fmt.Printf("population  %d\n", c.Width*c.Height-c.CellsPerState[0])
+	for s := 1; s < c.NumStates; s++ {
+		fmt.Printf("  state %-3d %d\n", s, c.CellsPerState[s])
+	}
+	if x, y, w, h, ok := c.InitGrid.BoundingBox(); ok {
+		fmt.Printf("bounding    %dx%d at (%d, %d)\n", w, h, x, y)
+	} else {
+		fmt.Printf("bounding    empty\n")
+	}
+	if *maxGenerations <= 0 {
+		return nil
+	}
+	res, err := cella.Analyze(c, cella.AnalyzeOptions{MaxGenerations: *maxGenerations})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("period      %s\n", res)
+	return nil
+}
+
+// viewCmd runs the interactive terminal viewer
+func viewCmd(args []string) error {
+	fs := flag.NewFlagSet("view", flag.ExitOnError)
+	var af automatonFlags
+	af.register(fs)
+	delay := fs.Duration("delay", 100*time.Millisecond, "delay between generations while playing")
+	colors256 := fs.Bool("256", false, "use 256 colors instead of truecolor")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && af.spec == "" {
+		return fmt.Errorf("the pattern must be given as a file, the standard input is used for keys")
+	}
+	la, err := af.load(fs)
+	if err != nil {
+		return err
+	}
+	v := cella.NewViewer(la.automaton)
+	v.Options.Palette = la.palette
+	if *colors256 {
+		v.Options.ColorMode = cella.ColorMode256
+	}
+	v.Delay = *delay
+	if err := v.RunTerminal(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stopped at generation %d\n", la.automaton.Generation)
+	return nil
+}
+
+// rulesCmd analyzes the rules of a rulestring, a Golly .rule file or a spec
+func rulesCmd(args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	rule := fs.String("rule", "B3/S23", "rulestring or Golly .rule file")
+	spec := fs.String("spec", "", "automaton definition file (JSON or YAML), used instead of -rule")
+	fs.Parse(args)
+
+	var rules []*cella.Rule2d
+	var numStates int
+	name := *rule
+	if *spec != "" {
+		s, err := cella.LoadSpecFile(*spec)
+		if err != nil {
+			return err
+		}
+		c, err := s.Build()
+		if err != nil {
+			return err
+		}
+		rules, numStates, name = c.Rules, c.NumStates, *spec
+	} else {
+		var err error
+		if rules, numStates, _, name, err = loadRules(*rule, true); err != nil {
+			return err
+		}
+	}
+	a, err := cella.AnalyzeRules(rules, numStates)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rule %s, %d states\n%s%s\n", name, numStates, a, a.Table.Metrics())
+	return nil
+}
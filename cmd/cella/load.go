@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/luis-ale-117/cella"
+)
+
+// automatonFlags are the flags used to load an automaton
+type automatonFlags struct {
+	spec     string
+	rule     string
+	width    int
+	height   int
+	pad      int
+	boundary string
+}
+
+// register adds the flags to the flag set
+func (f *automatonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.spec, "spec", "", "automaton definition file (JSON or YAML), used instead of a pattern")
+	fs.StringVar(&f.rule, "rule", "", "rulestring or Golly .rule file, the pattern rule or B3/S23 if empty")
+	fs.IntVar(&f.width, "width", 0, "width of the grid, the pattern width plus padding if 0")
+	fs.IntVar(&f.height, "height", 0, "height of the grid, the pattern height plus padding if 0")
+	fs.IntVar(&f.pad, "pad", 0, "dead cells added around the pattern when the size is not set")
+	fs.StringVar(&f.boundary, "boundary", "fixed", "boundary of the grid: fixed or toroidal")
+}
+
+// openInput opens the file given as argument, or the standard input
+func openInput(fs *flag.FlagSet) (io.ReadCloser, error) {
+	switch fs.NArg() {
+	case 0:
+		return io.NopCloser(os.Stdin), nil
+	case 1:
+		if fs.Arg(0) == "-" {
+			return io.NopCloser(os.Stdin), nil
+		}
+		return os.Open(fs.Arg(0))
+	}
+	return nil, fmt.Errorf("expected a single pattern, got %d", fs.NArg())
+}
+
+// createOutput creates the output file, or returns the standard output
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// nopWriteCloser does not close the standard output
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// readPattern reads the pattern given as argument
+func readPattern(fs *flag.FlagSet) (*cella.Grid, *cella.PatternInfo, error) {
+	in, err := openInput(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+	return cella.LoadPattern(bufio.NewReader(in))
+}
+
+// loadedAutomaton is an automaton with the information needed to write it back
+type loadedAutomaton struct {
+	automaton *cella.Cella2d
+	rule      string        // Rulestring or name of the rule
+	palette   cella.Palette // Colors of the states
+}
+
+// load creates the automaton from the spec or from the pattern and rule
+func (f *automatonFlags) load(fs *flag.FlagSet) (*loadedAutomaton, error) {
+	if f.spec != "" {
+		spec, err := cella.LoadSpecFile(f.spec)
+		if err != nil {
+			return nil, err
+		}
+		c, err := spec.Build()
+		if err != nil {
+			return nil, err
+		}
+		palette, err := spec.Palette()
+		if err != nil {
+			return nil, err
+		}
+		return &loadedAutomaton{automaton: c, rule: spec.Rulestring, palette: palette}, nil
+	}
+
+	pattern, info, err := readPattern(fs)
+	if err != nil {
+		return nil, err
+	}
+	// Only rules given with -rule can be files, the rule of a pattern could
+	// point to any file
+	rule, fromFlag := f.rule, true
+	if rule == "" {
+		rule, fromFlag = info.Rule, false
+	}
+	if rule == "" {
+		rule = "B3/S23"
+	}
+	rules, numStates, palette, rule, err := loadRules(rule, fromFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := f.width, f.height
+	if width <= 0 {
+		width = pattern.Width + 2*f.pad
+	}
+	if height <= 0 {
+		height = pattern.Height + 2*f.pad
+	}
+	if width < pattern.Width || height < pattern.Height {
+		return nil, fmt.Errorf("pattern %dx%d does not fit in a %dx%d grid", pattern.Width, pattern.Height, width, height)
+	}
+	c := cella.NewCella2d(width, height, numStates)
+	c.SetInitGrid(cella.NewGrid(width, height))
+	c.SetNextGrid(cella.NewGrid(width, height))
+	c.SetRules(rules)
+	switch f.boundary {
+	case "fixed":
+		c.SetBoundary(cella.BoundaryFixed)
+	case "toroidal":
+		c.SetBoundary(cella.BoundaryToroidal)
+	default:
+		return nil, fmt.Errorf("unknown boundary %q", f.boundary)
+	}
+	// The pattern is centered in the grid
+	x0, y0 := (width-pattern.Width)/2, (height-pattern.Height)/2
+	for y := 0; y < pattern.Height; y++ {
+		for x := 0; x < pattern.Width; x++ {
+			state := pattern.GetCell(x, y)
+			if int(state) >= numStates {
+				return nil, fmt.Errorf("state %d at (%d, %d) out of range for rule %s", state, x, y, rule)
+			}
+			c.InitGrid.SetCell(x0+x, y0+y, state)
+		}
+	}
+	c.CountCellsPerState()
+	return &loadedAutomaton{automaton: c, rule: rule, palette: palette}, nil
+}
+
+// loadRules creates the rules of a rulestring or, if allowFiles is set, of
+// a Golly .rule file, returning the number of states, the palette and the
+// name of the rule
+func loadRules(rule string, allowFiles bool) ([]*cella.Rule2d, int, cella.Palette, string, error) {
+	if strings.HasSuffix(rule, ".rule") {
+		if !allowFiles {
+			return nil, 0, nil, "", fmt.Errorf("rule %q of the pattern is not a rulestring, use -rule to load a .rule file", rule)
+		}
+		golly, err := cella.LoadGollyRuleFile(rule)
+		if err != nil {
+			return nil, 0, nil, "", err
+		}
+		return golly.Rules(), golly.NumStates, golly.Palette(), golly.Name, nil
+	}
+	// Golly appends the topology to the rule, e.g. "B3/S23:T20,20"
+	rs, err := cella.ParseRulestring(strings.SplitN(rule, ":", 2)[0])
+	if err != nil {
+		return nil, 0, nil, "", err
+	}
+	return rs.Rules(), rs.NumStates, cella.DefaultPalette(rs.NumStates), rs.String(), nil
+}
+
+// loadRulesFunc loads the rulestring or the Golly .rule file once and
+// returns a function creating new rules for each automaton that runs
+// concurrently, with the number of states and the name of the rule
+func loadRulesFunc(rule string) (func() []*cella.Rule2d, int, string, error) {
+	if strings.HasSuffix(rule, ".rule") {
+		golly, err := cella.LoadGollyRuleFile(rule)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		// Golly rules are copied since they are not safe for concurrent use
+		return func() []*cella.Rule2d { return golly.Copy().Rules() }, golly.NumStates, golly.Name, nil
+	}
+	rs, err := cella.ParseRulestring(strings.SplitN(rule, ":", 2)[0])
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return rs.Rules, rs.NumStates, rs.String(), nil
+}
@@ -0,0 +1,65 @@
+// Command cella runs, renders and converts cellular automata.
+//
+// Usage:
+//
+//	cella run     [flags] [pattern]   step an automaton and write the result
+//	cella render  [flags] [pattern]   render a pattern as PNG or animated GIF
+//	cella convert [flags] [pattern]   convert between pattern formats
+//	cella info    [flags] [pattern]   show population, bounding box and period
+//	cella view    [flags] [pattern]   interactive terminal viewer
+//	cella soup    [flags]             search random soups and take a census
+//	cella rules   [flags]             analyze a rule set and show its metrics
+//
+// Patterns are read from the given file or from the standard input if
+// omitted or "-". Results are written to the standard output unless -o is set.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands are the subcommands of cella
+var commands = map[string]func(args []string) error{
+	"run":     runCmd,
+	"render":  renderCmd,
+	"convert": convertCmd,
+	"info":    infoCmd,
+	"view":    viewCmd,
+	"soup":    soupCmd,
+	"rules":   rulesCmd,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: cella <command> [flags] [pattern]
+
+commands:
+  run      step an automaton N generations and write the result
+  render   render a pattern as PNG or animated GIF
+  convert  convert between pattern formats
+  info     show population, bounding box and period
+  view     interactive terminal viewer
+  soup     search random soups and take a census of the objects
+  rules    analyze a rule set: shadowed rules, gaps, properties and lambda
+
+Run "cella <command> -h" for the flags of each command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "cella: unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cella %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/luis-ale-117/cella"
+)
+
+// soupCmd searches random soups and writes the census of the objects found,
+// or merges the results of several searches
+func soupCmd(args []string) error {
+	fs := flag.NewFlagSet("soup", flag.ExitOnError)
+	rule := fs.String("rule", "B3/S23", "rulestring or Golly .rule file")
+	soups := fs.Int("n", 1000, "number of soups")
+	seed := fs.Int64("seed", 0, "seed of the first soup")
+	width := fs.Int("width", 16, "width of the soups")
+	height := fs.Int("height", 16, "height of the soups")
+	density := fs.Float64("density", 0.5, "density of the soups")
+	pad := fs.Int("pad", 32, "empty cells around each soup")
+	workers := fs.Int("workers", 0, "soups run in parallel, the number of CPUs if 0")
+	maxGenerations := fs.Int("max", 2000, "generations run waiting for a soup to stabilize")
+	connectivity := fs.String("connectivity", "life", "connectivity of the objects: 4, 8 or life")
+	output := fs.String("o", "", "results file (JSON), the standard output if empty")
+	merge := fs.Bool("merge", false, "merge the results files given as arguments instead of searching")
+	fs.Parse(args)
+
+	var res *cella.SoupResult
+	var searchErr error
+	if *merge {
+		var err error
+		if res, err = mergeSoupResults(fs.Args()); err != nil {
+			return err
+		}
+	} else {
+		rules, numStates, name, err := loadRulesFunc(*rule)
+		if err != nil {
+			return err
+		}
+		conn, err := cella.ParseConnectivity(*connectivity)
+		if err != nil {
+			return err
+		}
+		opts := cella.SoupOptions{
+			Rule:      name,
+			Rules:     rules,
+			NumStates: numStates,
+			Width:     *width,
+			Height:    *height,
+			Density:   *density,
+			Padding:   *pad,
+			Seed:      *seed,
+			Soups:     *soups,
+			Workers:   *workers,
+			Settle:    cella.AnalyzeOptions{MaxGenerations: *maxGenerations},
+			Census:    cella.CensusOptions{Connectivity: conn},
+		}
+		// An interrupt stops the search and the partial results are written
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		res, searchErr = cella.SearchSoups(ctx, opts)
+		if res == nil {
+			return searchErr
+		}
+		fmt.Fprintf(os.Stderr, "%d soups, %d unstable\n%s", res.Soups, res.Unstable, res.Census)
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := res.WriteJSON(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if searchErr == context.Canceled {
+		return nil
+	}
+	return searchErr
+}
+
+// mergeSoupResults reads and merges the results files
+func mergeSoupResults(paths []string) (*cella.SoupResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no results files to merge")
+	}
+	var merged *cella.SoupResult
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		res, err := cella.ReadSoupResult(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if merged == nil {
+			merged = res
+			continue
+		}
+		if err := merged.Merge(res, 0); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,210 @@
+package cella
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// HammingDistance returns the number of cells with different states in
+// both grids. Only the area common to both grids is compared.
+func HammingDistance(a, b *Grid) int {
+	w, h := a.Width, a.Height
+	if b.Width < w {
+		w = b.Width
+	}
+	if b.Height < h {
+		h = b.Height
+	}
+	d := 0
+	for y := 0; y < h; y++ {
+		ra, rb := a.Cells[y][:w], b.Cells[y][:w]
+		for x := range ra {
+			if ra[x] != rb[x] {
+				d++
+			}
+		}
+	}
+	return d
+}
+
+// damageCells changes n distinct random cells of the grid to another random state
+func damageCells(g *Grid, n, numStates int, rnd *rand.Rand) {
+	total := g.Width * g.Height
+	if n > total {
+		n = total
+	}
+	for _, i := range pickCells(total, n, rnd) {
+		x, y := i%g.Width, i/g.Width
+		s := Cell(rnd.Intn(numStates - 1))
+		if s >= g.Cells[y][x] {
+			s++
+		}
+		g.Cells[y][x] = s
+	}
+}
+
+// pickCells returns n distinct random indices lower than total with a
+// partial Fisher-Yates shuffle. When n is small the shuffled positions are
+// kept in a map, so the memory used does not depend on total.
+func pickCells(total, n int, rnd *rand.Rand) []int {
+	picked := make([]int, n)
+	if n*4 >= total {
+		perm := make([]int, total)
+		for i := range perm {
+			perm[i] = i
+		}
+		for i := 0; i < n; i++ {
+			j := i + rnd.Intn(total-i)
+			perm[i], perm[j] = perm[j], perm[i]
+		}
+		copy(picked, perm)
+		return picked
+	}
+	swapped := make(map[int]int, 2*n)
+	at := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i
+	}
+	for i := 0; i < n; i++ {
+		j := i + rnd.Intn(total-i)
+		picked[i] = at(j)
+		swapped[j] = at(i)
+	}
+	return picked
+}
+
+// DamageOptions are the options of a damage spreading analysis
+type DamageOptions struct {
+	Generations int   // Generations run after the damage, 100 if not set
+	Cells       int   // Cells changed in the damaged copy, 1 if not set
+	Trials      int   // Number of damaged copies, each with different cells changed, 1 if not set
+	Seed        int64 // Seed of the cells changed
+}
+
+// DamageResult is the result of a damage spreading analysis
+type DamageResult struct {
+	Distances  [][]int   // Hamming distance of each trial, from the damage to the last generation
+	Mean       []float64 // Mean distance of the trials in each generation
+	Healed     int       // Trials where the damage disappeared in the last generation
+	GrowthRate float64   // Mean change of the mean distance per generation
+	Lyapunov   float64   // Exponential growth rate of the mean distance, -Inf if it healed
+}
+
+// DamageSpreading measures the sensitivity of the automaton to small
+// changes. For each trial it clones the automaton, changes some cells of
+// the copy and runs both in lockstep recording the Hamming distance
+// between their grids. The automaton is not modified.
+func DamageSpreading(c *Cella2d, opts DamageOptions) (*DamageResult, error) {
+	if opts.Generations <= 0 {
+		opts.Generations = 100
+	}
+	if opts.Cells <= 0 {
+		opts.Cells = 1
+	}
+	if opts.Trials <= 0 {
+		opts.Trials = 1
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	res := &DamageResult{Mean: make([]float64, opts.Generations+1)}
+	for trial := 0; trial < opts.Trials; trial++ {
+		orig, damaged := c.Clone(), c.Clone()
+		damageCells(damaged.InitGrid, opts.Cells, c.NumStates, rnd)
+		dist := make([]int, 0, opts.Generations+1)
+		for gen := 0; ; gen++ {
+			d := HammingDistance(orig.InitGrid, damaged.InitGrid)
+			dist = append(dist, d)
+			res.Mean[gen] += float64(d)
+			if gen == opts.Generations {
+				break
+			}
+			for _, a := range []*Cella2d{orig, damaged} {
+				if err := a.Step(); err != nil {
+					return nil, fmt.Errorf("damage: %w", err)
+				}
+			}
+		}
+		if dist[len(dist)-1] == 0 {
+			res.Healed++
+		}
+		res.Distances = append(res.Distances, dist)
+	}
+	for i := range res.Mean {
+		res.Mean[i] /= float64(opts.Trials)
+	}
+	first, last := res.Mean[0], res.Mean[opts.Generations]
+	res.GrowthRate = (last - first) / float64(opts.Generations)
+	res.Lyapunov = (math.Log(last) - math.Log(first)) / float64(opts.Generations)
+	return res, nil
+}
+
+// DerridaOptions are the options of a Derrida plot
+type DerridaOptions struct {
+	Points  int     // Initial distances measured, evenly spaced in (0, 1], 10 if not set
+	Samples int     // Random grids for each point, 10 if not set
+	Density float64 // Probability of a cell of the random grids not being in state 0, 0.5 if not set
+	Seed    int64   // Seed of the random grids
+}
+
+// DerridaPoint is a point of a Derrida plot
+type DerridaPoint struct {
+	X float64 // Normalized Hamming distance before the generation
+	Y float64 // Mean normalized Hamming distance after the generation
+}
+
+// DerridaPlot measures how the distance between two random grids changes
+// in one generation. For each initial distance, random grids of the size of
+// the automaton are run with the rules of the automaton, together with a
+// copy with that fraction of cells changed. Points above the diagonal near
+// the origin mean that small damage grows, as in chaotic rules.
+// The automaton is not modified.
+func DerridaPlot(c *Cella2d, opts DerridaOptions) ([]DerridaPoint, error) {
+	if opts.Points <= 0 {
+		opts.Points = 10
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = 10
+	}
+	if opts.Density <= 0 {
+		opts.Density = 0.5
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	total := c.Width * c.Height
+	a, b := c.Clone(), c.Clone()
+	points := make([]DerridaPoint, opts.Points)
+	for p := range points {
+		n := int(math.Round(float64(p+1) / float64(opts.Points) * float64(total)))
+		if n < 1 {
+			n = 1
+		}
+		sum := 0
+		for s := 0; s < opts.Samples; s++ {
+			a.SetInitGrid(NewSoup(c.Width, c.Height, c.NumStates, opts.Density, rnd.Int63()))
+			b.SetInitGrid(a.InitGrid.Clone())
+			damageCells(b.InitGrid, n, c.NumStates, rnd)
+			for _, x := range []*Cella2d{a, b} {
+				if err := x.Step(); err != nil {
+					return nil, fmt.Errorf("derrida: %w", err)
+				}
+			}
+			sum += HammingDistance(a.InitGrid, b.InitGrid)
+		}
+		points[p] = DerridaPoint{
+			X: float64(n) / float64(total),
+			Y: float64(sum) / float64(opts.Samples*total),
+		}
+	}
+	return points, nil
+}
+
+// DerridaCoefficient returns the slope of the Derrida plot at the origin,
+// estimated with its first point. Rules with a coefficient greater than 1
+// spread small damage.
+func DerridaCoefficient(points []DerridaPoint) float64 {
+	if len(points) == 0 || points[0].X == 0 {
+		return 0
+	}
+	return points[0].Y / points[0].X
+}
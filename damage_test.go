@@ -0,0 +1,77 @@
+package cella
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	ca.InitGrid.SetCell(1, 1, 1)
+	cp := ca.Clone()
+	cp.InitGrid.SetCell(2, 2, 1)
+	if ca.InitGrid.GetCell(2, 2) != 0 || cp.InitGrid.GetCell(1, 1) != 1 || len(cp.Rules) != 3 {
+		t.Fatal("Clone shares the grid")
+	}
+	if d := HammingDistance(ca.InitGrid, cp.InitGrid); d != 1 {
+		t.Fatalf("Hamming distance is %d", d)
+	}
+}
+
+func TestDamageSpreading(t *testing.T) {
+	// Without rules the damage stays the same
+	ca := newGameOfLife(6, 6)
+	ca.SetRules(nil)
+	res, err := DamageSpreading(ca, DamageOptions{Generations: 5, Cells: 3, Trials: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Distances) != 2 || res.Mean[5] != 3 || res.GrowthRate != 0 || res.Lyapunov != 0 || res.Healed != 0 {
+		t.Fatalf("Damage of the identity does not match: %+v", res)
+	}
+
+	// A single live cell dies in an empty grid of Life
+	ca = newGameOfLife(6, 6)
+	res, err = DamageSpreading(ca, DamageOptions{Generations: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Healed != 1 || res.Distances[0][0] != 1 || !math.IsInf(res.Lyapunov, -1) || ca.Generation != 0 {
+		t.Fatalf("Damage in Life does not match: %+v", res)
+	}
+}
+
+func TestDerridaPlot(t *testing.T) {
+	ca := newGameOfLife(8, 8)
+	ca.SetRules(nil)
+	points, err := DerridaPlot(ca, DerridaOptions{Points: 4, Samples: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range points {
+		if p.X != p.Y {
+			t.Fatalf("Derrida plot of the identity is not the diagonal: %v", points)
+		}
+	}
+	if DerridaCoefficient(points) != 1 {
+		t.Fatal("Derrida coefficient of the identity is not 1")
+	}
+}
+
+func TestPickCells(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct{ total, n int }{{10, 10}, {10, 3}, {4096 * 4096, 100}} {
+		picked := pickCells(test.total, test.n, rnd)
+		seen := make(map[int]bool)
+		for _, i := range picked {
+			if i < 0 || i >= test.total || seen[i] {
+				t.Fatalf("Cells picked from %d are not distinct: %v", test.total, picked)
+			}
+			seen[i] = true
+		}
+		if len(picked) != test.n {
+			t.Fatalf("%d cells picked, expected %d", len(picked), test.n)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cella
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CellChange is a change of state of a cell
+type CellChange struct {
+	X, Y int  // Position of the cell
+	Old  Cell // State before the change
+	New  Cell // State after the change
+}
+
+// Diff returns the cells with different states in both grids, in row
+// order, with the state in a as Old and the state in b as New.
+// Grids of different size differ in every cell outside of one of them,
+// which are reported with state 0 in the grid they are missing from.
+func Diff(a, b *Grid) []CellChange {
+	w, h := a.Width, a.Height
+	if b.Width > w {
+		w = b.Width
+	}
+	if b.Height > h {
+		h = b.Height
+	}
+	var changes []CellChange
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			inA, inB := a.InBounds(x, y), b.InBounds(x, y)
+			if !inA && !inB {
+				continue
+			}
+			var o, n Cell
+			if inA {
+				o = a.Cells[y][x]
+			}
+			if inB {
+				n = b.Cells[y][x]
+			}
+			if o != n || inA != inB {
+				changes = append(changes, CellChange{x, y, o, n})
+			}
+		}
+	}
+	return changes
+}
+
+// String returns the change as "(x, y): old -> new"
+func (c CellChange) String() string {
+	return fmt.Sprintf("(%d, %d): %d -> %d", c.X, c.Y, c.Old, c.New)
+}
+
+// Delta is the list of changes that turn a grid into another one of the
+// same size
+type Delta struct {
+	Width, Height int          // Size of the grids
+	Changes       []CellChange // Cells changed, in row order
+}
+
+// NewDelta returns the changes from grid a to grid b
+func NewDelta(a, b *Grid) (*Delta, error) {
+	if a.Width != b.Width || a.Height != b.Height {
+		return nil, fmt.Errorf("delta: grids of different size %dx%d and %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	return &Delta{Width: a.Width, Height: a.Height, Changes: Diff(a, b)}, nil
+}
+
+// check checks that the grid has the size of the delta and that its
+// cells are in the states expected before the changes
+func (d *Delta) check(g *Grid, revert bool) error {
+	if g.Width != d.Width || g.Height != d.Height {
+		return fmt.Errorf("delta: grid is %dx%d, delta is %dx%d", g.Width, g.Height, d.Width, d.Height)
+	}
+	for _, c := range d.Changes {
+		if c.X < 0 || c.Y < 0 || c.X >= d.Width || c.Y >= d.Height {
+			return fmt.Errorf("delta: change %s out of the grid", c)
+		}
+		expected := c.Old
+		if revert {
+			expected = c.New
+		}
+		if s := g.Cells[c.Y][c.X]; s != expected {
+			return fmt.Errorf("delta: cell (%d, %d) is in state %d, expected %d", c.X, c.Y, s, expected)
+		}
+	}
+	return nil
+}
+
+// Apply sets the cells of the grid to their new states. The grid is not
+// changed and an error is returned if a cell is not in its old state.
+func (d *Delta) Apply(g *Grid) error {
+	if err := d.check(g, false); err != nil {
+		return err
+	}
+	for _, c := range d.Changes {
+		g.Cells[c.Y][c.X] = c.New
+	}
+	return nil
+}
+
+// Revert sets the cells of the grid back to their old states. The grid is
+// not changed and an error is returned if a cell is not in its new state.
+func (d *Delta) Revert(g *Grid) error {
+	if err := d.check(g, true); err != nil {
+		return err
+	}
+	for _, c := range d.Changes {
+		g.Cells[c.Y][c.X] = c.Old
+	}
+	return nil
+}
+
+// Len returns the number of cells changed
+func (d *Delta) Len() int {
+	return len(d.Changes)
+}
+
+// maxDeltaString limits the number of changes listed by String
+const maxDeltaString = 20
+
+// String returns the size of the delta and its first changes, one per line
+func (d *Delta) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d cells changed in %dx%d grid", len(d.Changes), d.Width, d.Height)
+	for i, c := range d.Changes {
+		if i == maxDeltaString {
+			fmt.Fprintf(&sb, "\n...%d more", len(d.Changes)-i)
+			break
+		}
+		sb.WriteString("\n")
+		sb.WriteString(c.String())
+	}
+	return sb.String()
+}
+
+// Binary format of a Delta.
+// A delta starts with a magic string and a version byte, followed by
+// unsigned varints:
+//
+//	width, height, len(changes),
+//	(cells skipped since the previous change, old, new)...
+//
+// Changes are stored in row order, positions are counted in row order.
+const (
+	deltaMagic   = "CLD"
+	deltaVersion = 1
+)
+
+// MarshalBinary encodes the delta. The changes must be in row order, as
+// returned by Diff.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	buf := []byte(deltaMagic)
+	buf = append(buf, deltaVersion)
+	buf = binary.AppendUvarint(buf, uint64(d.Width))
+	buf = binary.AppendUvarint(buf, uint64(d.Height))
+	buf = binary.AppendUvarint(buf, uint64(len(d.Changes)))
+	next := 0
+	for _, c := range d.Changes {
+		pos := c.Y*d.Width + c.X
+		if c.X < 0 || c.X >= d.Width || pos < next || pos >= d.Width*d.Height {
+			return nil, fmt.Errorf("delta: change %s out of the grid or of row order", c)
+		}
+		buf = binary.AppendUvarint(buf, uint64(pos-next))
+		buf = append(buf, byte(c.Old), byte(c.New))
+		next = pos + 1
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a delta encoded by MarshalBinary
+func (d *Delta) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(deltaMagic)) || len(data) < len(deltaMagic)+1 {
+		return fmt.Errorf("delta: invalid magic")
+	}
+	if v := data[len(deltaMagic)]; v != deltaVersion {
+		return fmt.Errorf("delta: unsupported version %d", v)
+	}
+	r := bytes.NewReader(data[len(deltaMagic)+1:])
+	var vals [3]uint64
+	for i := range vals {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+		vals[i] = v
+	}
+	w, h, n := vals[0], vals[1], vals[2]
+	if w == 0 || h == 0 || w*h/h != w || w*h > maxSnapshotCells || n > w*h {
+		return fmt.Errorf("delta: invalid size %dx%d with %d changes", w, h, n)
+	}
+	// Each change takes at least 3 bytes
+	if n > uint64(r.Len()/3) {
+		return fmt.Errorf("delta: %d changes in %d bytes", n, r.Len())
+	}
+	changes := make([]CellChange, 0, n)
+	next := uint64(0)
+	for i := uint64(0); i < n; i++ {
+		skip, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+		pos := next + skip
+		if skip >= w*h || pos >= w*h {
+			return fmt.Errorf("delta: change %d out of the grid", i)
+		}
+		old, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+		cur, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+		changes = append(changes, CellChange{int(pos % w), int(pos / w), Cell(old), Cell(cur)})
+		next = pos + 1
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("delta: %d trailing bytes", r.Len())
+	}
+	d.Width, d.Height, d.Changes = int(w), int(h), changes
+	return nil
+}
@@ -0,0 +1,59 @@
+package cella
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := NewGrid(4, 3)
+	b := NewGrid(4, 3)
+	a.SetCell(3, 0, 2)
+	b.SetCell(0, 1, 1)
+	b.SetCell(3, 2, 3)
+	changes := Diff(a, b)
+	expected := []CellChange{{3, 0, 2, 0}, {0, 1, 0, 1}, {3, 2, 0, 3}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Diff does not match: %v", changes)
+	}
+
+	// Cells outside of the smaller grid are always different
+	changes = Diff(NewGrid(2, 1), gridFromRows("001", "100"))
+	expected = []CellChange{{2, 0, 0, 1}, {0, 1, 0, 1}, {1, 1, 0, 0}, {2, 1, 0, 0}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Diff of grids of different size does not match: %v", changes)
+	}
+
+	d, err := NewDelta(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Delta
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, d) {
+		t.Fatalf("Decoded delta does not match:\n%s", &decoded)
+	}
+
+	g := a.Clone()
+	if err := decoded.Apply(g); err != nil || !EqualsGrid(g, b) {
+		t.Fatalf("Applied delta does not match: %v", err)
+	}
+	if err := decoded.Apply(g); err == nil {
+		t.Fatal("Delta applied twice")
+	}
+	if err := decoded.Revert(g); err != nil || !EqualsGrid(g, a) {
+		t.Fatalf("Reverted delta does not match: %v", err)
+	}
+	if _, err := NewDelta(a, NewGrid(3, 3)); err == nil {
+		t.Fatal("Delta of grids of different size")
+	}
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("Truncated delta decoded")
+	}
+}
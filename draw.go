@@ -0,0 +1,243 @@
+package cella
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Drawing operations change the cells of the grid in place. Shapes are
+// clipped to the grid, so they can be partially or totally outside of it.
+
+// Point is the position of a cell in a grid
+type Point struct {
+	X, Y int
+}
+
+// plot sets the cell to the state if it is inside of the grid
+func (g *Grid) plot(x, y int, state Cell) {
+	if g.InBounds(x, y) {
+		g.Cells[y][x] = state
+	}
+}
+
+// DrawLine draws a line from (x0, y0) to (x1, y1), both ends included,
+// with the Bresenham algorithm
+func (g *Grid) DrawLine(x0, y0, x1, y1 int, state Cell) {
+	dx, sx := x1-x0, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := y1-y0, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	err := dx - dy
+	for {
+		g.plot(x0, y0, state)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// DrawRect draws the outline of the rectangle of size w x h starting at
+// (x, y). Use FillRect to fill it.
+func (g *Grid) DrawRect(x, y, w, h int, state Cell) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	x1, y1 := x+w-1, y+h-1
+	g.DrawLine(x, y, x1, y, state)
+	g.DrawLine(x, y1, x1, y1, state)
+	g.DrawLine(x, y, x, y1, state)
+	g.DrawLine(x1, y, x1, y1, state)
+}
+
+// insideEllipse reports if the offset (dx, dy) from the centre is inside
+// the ellipse of radii rx and ry
+func insideEllipse(dx, dy, rx, ry int) bool {
+	return dx*dx*ry*ry+dy*dy*rx*rx <= rx*rx*ry*ry
+}
+
+// ellipse sets the cells of the ellipse, only those on its border if
+// outline is set. A cell is on the border if it is inside of the ellipse
+// and a cell next to it by a side is not.
+func (g *Grid) ellipse(cx, cy, rx, ry int, state Cell, outline bool) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	x0, y0, x1, y1, ok := g.clip(cx-rx, cy-ry, 2*rx+1, 2*ry+1)
+	if !ok {
+		return
+	}
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			dx, dy := x-cx, y-cy
+			if !insideEllipse(dx, dy, rx, ry) {
+				continue
+			}
+			if outline && insideEllipse(dx-1, dy, rx, ry) && insideEllipse(dx+1, dy, rx, ry) &&
+				insideEllipse(dx, dy-1, rx, ry) && insideEllipse(dx, dy+1, rx, ry) {
+				continue
+			}
+			g.Cells[y][x] = state
+		}
+	}
+}
+
+// DrawEllipse draws the outline of the ellipse centred at (cx, cy) with
+// radii rx and ry
+func (g *Grid) DrawEllipse(cx, cy, rx, ry int, state Cell) {
+	g.ellipse(cx, cy, rx, ry, state, true)
+}
+
+// FillEllipse fills the ellipse centred at (cx, cy) with radii rx and ry
+func (g *Grid) FillEllipse(cx, cy, rx, ry int, state Cell) {
+	g.ellipse(cx, cy, rx, ry, state, false)
+}
+
+// DrawCircle draws the outline of the circle centred at (cx, cy)
+func (g *Grid) DrawCircle(cx, cy, r int, state Cell) {
+	g.ellipse(cx, cy, r, r, state, true)
+}
+
+// FillCircle fills the circle centred at (cx, cy)
+func (g *Grid) FillCircle(cx, cy, r int, state Cell) {
+	g.ellipse(cx, cy, r, r, state, false)
+}
+
+// DrawPolygon draws the outline of the polygon, the last point is joined
+// to the first one
+func (g *Grid) DrawPolygon(points []Point, state Cell) {
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		g.DrawLine(p.X, p.Y, q.X, q.Y, state)
+	}
+}
+
+// FillPolygon fills the polygon with the even-odd rule, its outline
+// included. The polygon can be concave or self-intersecting.
+func (g *Grid) FillPolygon(points []Point, state Cell) {
+	if len(points) == 0 {
+		return
+	}
+	minY, maxY := points[0].Y, points[0].Y
+	for _, p := range points {
+		if p.Y < minY {
+			minY = p.Y
+		}
+		if p.Y > maxY {
+			maxY = p.Y
+		}
+	}
+	if minY < 0 {
+		minY = 0
+	}
+	if maxY >= g.Height {
+		maxY = g.Height - 1
+	}
+	var xs []float64
+	for y := minY; y <= maxY; y++ {
+		xs = xs[:0]
+		for i, a := range points {
+			b := points[(i+1)%len(points)]
+			// Edges are half open so vertices are not counted twice
+			if (a.Y <= y && y < b.Y) || (b.Y <= y && y < a.Y) {
+				xs = append(xs, float64(a.X)+float64(y-a.Y)*float64(b.X-a.X)/float64(b.Y-a.Y))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0, x1 := int(math.Ceil(xs[i])), int(math.Floor(xs[i+1]))
+			if x0 < 0 {
+				x0 = 0
+			}
+			if x1 >= g.Width {
+				x1 = g.Width - 1
+			}
+			for x := x0; x <= x1; x++ {
+				g.Cells[y][x] = state
+			}
+		}
+	}
+	g.DrawPolygon(points, state)
+}
+
+// FloodFill sets to the state the cell at (x, y) and all the cells
+// connected to it in its same state. It returns the number of cells
+// changed, 0 if the cell is outside of the grid or already in the state.
+func (g *Grid) FloodFill(x, y int, state Cell, conn Connectivity) int {
+	if !g.InBounds(x, y) {
+		return 0
+	}
+	target := g.Cells[y][x]
+	if target == state {
+		return 0
+	}
+	offs := conn.offsets()
+	g.Cells[y][x] = state
+	n := 1
+	stack := []Point{{x, y}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, o := range offs {
+			nx, ny := p.X+o[0], p.Y+o[1]
+			if !g.InBounds(nx, ny) || g.Cells[ny][nx] != target {
+				continue
+			}
+			g.Cells[ny][nx] = state
+			n++
+			stack = append(stack, Point{nx, ny})
+		}
+	}
+	return n
+}
+
+// RandomFill sets each cell of the rectangle of size w x h starting at
+// (x, y) to state s with probability densities[s]. Cells keep their state
+// with the remaining probability. The same random source gives the same
+// cells, so seeded sources are reproducible.
+func (g *Grid) RandomFill(x, y, w, h int, densities []float64, rnd *rand.Rand) error {
+	sum := 0.0
+	for s, d := range densities {
+		if d < 0 || math.IsNaN(d) {
+			return fmt.Errorf("draw: invalid density %v of state %d", d, s)
+		}
+		sum += d
+	}
+	if sum > 1+1e-9 {
+		return fmt.Errorf("draw: densities add up to %v, more than 1", sum)
+	}
+	if len(densities) > 256 {
+		return fmt.Errorf("draw: %d densities, more than the 256 states of a cell", len(densities))
+	}
+	x0, y0, x1, y1, ok := g.clip(x, y, w, h)
+	if !ok {
+		return nil
+	}
+	for cy := y0; cy < y1; cy++ {
+		for cx := x0; cx < x1; cx++ {
+			r := rnd.Float64()
+			for s, d := range densities {
+				if r < d {
+					g.Cells[cy][cx] = Cell(s)
+					break
+				}
+				r -= d
+			}
+		}
+	}
+	return nil
+}
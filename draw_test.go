@@ -0,0 +1,107 @@
+package cella
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDrawShapes(t *testing.T) {
+	triangle := []Point{{0, 0}, {4, 0}, {0, 4}}
+	tests := []struct {
+		name     string
+		draw     func(g *Grid)
+		expected *Grid
+	}{
+		{"line", func(g *Grid) { g.DrawLine(0, 0, 4, 2, 1) },
+			gridFromRows("11000", "00110", "00001", "00000", "00000")},
+		{"reversed line", func(g *Grid) { g.DrawLine(4, 2, 0, 0, 1) },
+			gridFromRows("10000", "01100", "00011", "00000", "00000")},
+		{"vertical line", func(g *Grid) { g.DrawLine(1, 4, 1, -2, 1) },
+			gridFromRows("01000", "01000", "01000", "01000", "01000")},
+		{"rect", func(g *Grid) { g.DrawRect(0, 0, 4, 3, 1) },
+			gridFromRows("11110", "10010", "11110", "00000", "00000")},
+		{"circle", func(g *Grid) { g.DrawCircle(2, 2, 2, 1) },
+			gridFromRows("00100", "01010", "10001", "01010", "00100")},
+		{"filled circle", func(g *Grid) { g.FillCircle(2, 2, 2, 1) },
+			gridFromRows("00100", "01110", "11111", "01110", "00100")},
+		{"filled ellipse", func(g *Grid) { g.FillEllipse(2, 1, 2, 1, 1) },
+			gridFromRows("00100", "11111", "00100", "00000", "00000")},
+		{"clipped circle", func(g *Grid) { g.FillCircle(0, 0, 2, 1) },
+			gridFromRows("11100", "11000", "10000", "00000", "00000")},
+		{"polygon", func(g *Grid) { g.DrawPolygon(triangle, 1) },
+			gridFromRows("11111", "10010", "10100", "11000", "10000")},
+		{"filled polygon", func(g *Grid) { g.FillPolygon(triangle, 1) },
+			gridFromRows("11111", "11110", "11100", "11000", "10000")},
+	}
+	for _, test := range tests {
+		g := NewGrid(5, 5)
+		test.draw(g)
+		if !EqualsGrid(g, test.expected) {
+			t.Fatalf("%s does not match: %v", test.name, Diff(test.expected, g))
+		}
+	}
+}
+
+func TestFloodFill(t *testing.T) {
+	rows := []string{
+		"1110",
+		"1010",
+		"1110",
+		"0001",
+	}
+	tests := []struct {
+		x, y     int
+		conn     Connectivity
+		filled   int
+		expected *Grid
+	}{
+		{1, 1, Connectivity4, 1, gridFromRows("1110", "1210", "1110", "0001")},
+		{0, 3, Connectivity4, 3, gridFromRows("1110", "1010", "1110", "2221")},
+		{0, 3, Connectivity8, 6, gridFromRows("1112", "1012", "1112", "2221")},
+		{0, 0, Connectivity4, 8, gridFromRows("2220", "2020", "2220", "0001")},
+		{5, 0, Connectivity4, 0, gridFromRows(rows...)},
+	}
+	for _, test := range tests {
+		g := gridFromRows(rows...)
+		if n := g.FloodFill(test.x, test.y, 2, test.conn); n != test.filled {
+			t.Fatalf("FloodFill at (%d, %d) filled %d cells, expected %d", test.x, test.y, n, test.filled)
+		}
+		if !EqualsGrid(g, test.expected) {
+			t.Fatalf("FloodFill at (%d, %d) does not match: %v", test.x, test.y, Diff(test.expected, g))
+		}
+	}
+}
+
+func TestRandomFill(t *testing.T) {
+	densities := []float64{0, 0.3, 0.2}
+	g := NewGrid(100, 100)
+	if err := g.RandomFill(0, 0, 100, 100, densities, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	counts := make([]int, 3)
+	for _, row := range g.Cells {
+		for _, s := range row {
+			counts[s]++
+		}
+	}
+	if counts[1] < 2700 || counts[1] > 3300 || counts[2] < 1700 || counts[2] > 2300 {
+		t.Fatalf("Unexpected cells per state %v", counts)
+	}
+	other := NewGrid(100, 100)
+	other.RandomFill(0, 0, 100, 100, densities, rand.New(rand.NewSource(1)))
+	if !EqualsGrid(g, other) {
+		t.Fatal("Same seed gives different grids")
+	}
+
+	g = NewGrid(4, 4)
+	g.RandomFill(2, 2, 4, 4, []float64{0, 1}, rand.New(rand.NewSource(1)))
+	if !EqualsGrid(g, gridFromRows("0000", "0000", "0011", "0011")) {
+		t.Fatal("RandomFill is not clipped to the region")
+	}
+	if err := g.RandomFill(0, 0, 4, 4, []float64{0.6, 0.6}, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("Densities adding up to more than 1 must fail")
+	}
+	if err := g.RandomFill(0, 0, 4, 4, []float64{-0.1}, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("Negative densities must fail")
+	}
+}
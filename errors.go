@@ -0,0 +1,46 @@
+package cella
+
+import "fmt"
+
+// OutOfBoundsError is returned when a position is outside of a grid
+type OutOfBoundsError struct {
+	X, Y          int // Position of the cell
+	Width, Height int // Size of the grid
+}
+
+func (e *OutOfBoundsError) Error() string {
+	return fmt.Sprintf("cell (%d, %d) out of the %dx%d grid", e.X, e.Y, e.Width, e.Height)
+}
+
+// InvalidStateError is returned when a cell is in a state that the
+// automaton does not have. Cells of the auxiliar borders have x or y equal
+// to -1, Width or Height.
+type InvalidStateError struct {
+	X, Y      int  // Position of the cell
+	State     Cell // State of the cell
+	NumStates int  // Number of states of the automaton
+}
+
+func (e *InvalidStateError) Error() string {
+	return fmt.Sprintf("cell (%d, %d) in state %d out of range [0, %d)", e.X, e.Y, e.State, e.NumStates)
+}
+
+// RuleError is returned when a rule fails while calculating a generation
+type RuleError struct {
+	Rule       int    // Index of the rule in the rules of the automaton
+	Condition  string // Condition of the rule
+	X, Y       int    // Position of the cell being calculated, -1 if no cell was calculated yet
+	Generation int    // Generation being calculated from
+	Err        error  // Error of the rule
+}
+
+func (e *RuleError) Error() string {
+	if e.X < 0 || e.Y < 0 {
+		return fmt.Sprintf("rule %d {%s}: %v", e.Rule, e.Condition, e.Err)
+	}
+	return fmt.Sprintf("rule %d {%s} at cell (%d, %d): %v", e.Rule, e.Condition, e.X, e.Y, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,125 @@
+package cella
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckedAccessors(t *testing.T) {
+	g := NewGrid(3, 2)
+	if err := g.SetCellChecked(2, 1, 4); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := g.GetCellChecked(2, 1); err != nil || s != 4 {
+		t.Fatalf("GetCellChecked returned %d, %v", s, err)
+	}
+	for _, p := range []Point{{-1, 0}, {0, -1}, {3, 0}, {0, 2}} {
+		var oob *OutOfBoundsError
+		if err := g.SetCellChecked(p.X, p.Y, 1); !errors.As(err, &oob) || oob.X != p.X || oob.Y != p.Y || oob.Width != 3 {
+			t.Fatalf("SetCellChecked at %v returned %v", p, err)
+		}
+		if _, err := g.GetCellChecked(p.X, p.Y); !errors.As(err, &oob) {
+			t.Fatalf("GetCellChecked at %v returned %v", p, err)
+		}
+	}
+
+	var inv *InvalidStateError
+	if err := g.CheckStates(4); !errors.As(err, &inv) || inv.X != 2 || inv.Y != 1 || inv.State != 4 {
+		t.Fatalf("CheckStates returned %v", err)
+	}
+	g.SetAuxBorderUp([]Cell{0, 0, 0, 7})
+	if err := g.CheckStates(5); !errors.As(err, &inv) || inv.X != 2 || inv.Y != -1 {
+		t.Fatalf("CheckStates of the auxiliar border returned %v", err)
+	}
+	if err := g.CheckStates(8); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCella2dSetCell(t *testing.T) {
+	ca := newGameOfLife(3, 3)
+	if err := ca.SetCell(1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := ca.GetCell(1, 1); err != nil || s != 1 {
+		t.Fatalf("GetCell returned %d, %v", s, err)
+	}
+	var inv *InvalidStateError
+	if err := ca.SetCell(1, 1, 2); !errors.As(err, &inv) || inv.NumStates != 2 {
+		t.Fatalf("SetCell with an invalid state returned %v", err)
+	}
+	var oob *OutOfBoundsError
+	if err := ca.SetCell(3, 1, 1); !errors.As(err, &oob) {
+		t.Fatalf("SetCell out of the grid returned %v", err)
+	}
+	if _, err := ca.GetCell(1, 3); !errors.As(err, &oob) {
+		t.Fatalf("GetCell out of the grid returned %v", err)
+	}
+}
+
+func TestNextGenerationErrors(t *testing.T) {
+	ca := newGameOfLife(3, 3)
+	ca.InitGrid.SetCell(2, 0, 5)
+	var inv *InvalidStateError
+	if err := ca.Step(); !errors.As(err, &inv) || inv.X != 2 || inv.Y != 0 || inv.State != 5 {
+		t.Fatalf("Step with an invalid state returned %v", err)
+	}
+	ca.CountCellsPerState()
+	if ca.Generation != 0 || ca.CellsPerState[0] != 8 {
+		t.Fatal("Invalid state changed the automaton")
+	}
+
+	ca = newGameOfLife(3, 3)
+	ca.InitGrid.SetAuxBorderDown([]Cell{0, 0, 0, 0, 9})
+	if err := ca.Step(); !errors.As(err, &inv) || inv.X != 3 || inv.Y != 3 || inv.State != 9 {
+		t.Fatalf("Step with an invalid state in the auxiliar border returned %v", err)
+	}
+	ca.SetBoundary(BoundaryToroidal)
+	if err := ca.Step(); err != nil {
+		t.Fatalf("Toroidal borders must be recalculated: %v", err)
+	}
+
+	ca = newGameOfLife(3, 3)
+	ca.SetRules([]*Rule2d{NewRule2d("n11 == 0", 3, 2)})
+	var ruleErr *RuleError
+	if err := ca.Validate(); !errors.As(err, &ruleErr) || ruleErr.Rule != 0 || ruleErr.X != -1 {
+		t.Fatalf("Validate of a rule with an invalid state returned %v", err)
+	}
+	if err := ca.NextGeneration(); !errors.As(err, &ruleErr) || ruleErr.Rule != 0 || ruleErr.X != 0 || ruleErr.Y != 0 {
+		t.Fatalf("Rule with an invalid state returned %v", err)
+	}
+
+	ca = newGameOfLife(3, 3)
+	ca.SetRules([]*Rule2d{NewRule2d("n11 == 0", 1, 2), NewRule2d("s1 + 1", 0, 2)})
+	ca.InitGrid.SetCell(0, 0, 1)
+	err := ca.NextGeneration()
+	if !errors.As(err, &ruleErr) || ruleErr.Rule != 1 || ruleErr.Condition != "s1 + 1" || ruleErr.X != 0 || ruleErr.Y != 0 {
+		t.Fatalf("Failing rule returned %v", err)
+	}
+	if errors.Unwrap(err) == nil {
+		t.Fatal("RuleError does not wrap the error of the rule")
+	}
+
+	ca = NewCella2d(3, 3, 2)
+	if err := ca.NextGeneration(); err == nil {
+		t.Fatal("Automaton without grids must fail")
+	}
+
+	// Grids smaller than the automaton are rejected before the toroidal
+	// borders are set
+	ca = NewCella2d(5, 5, 2)
+	ca.SetBoundary(BoundaryToroidal)
+	ca.SetInitGrid(NewGrid(3, 3))
+	ca.SetNextGrid(NewGrid(3, 3))
+	if err := ca.Step(); err == nil {
+		t.Fatal("Grids smaller than the automaton must fail")
+	}
+}
+
+func TestRuleCountsInvalidStates(t *testing.T) {
+	r := NewRule2d("s1 == 1", 1, 2)
+	r.SetNeighbourhood([][]Cell{{0, 9, 0}, {1, 9, 0}, {0, 0, 0}})
+	if ok, err := r.CheckCondition(); err != nil || !ok {
+		t.Fatalf("Rule returned %v, %v", ok, err)
+	}
+}
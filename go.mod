@@ -3,3 +3,10 @@ module github.com/luis-ale-117/cella
 go 1.19
 
 require github.com/maja42/goval v1.3.1
+
+require (
+	golang.org/x/term v0.13.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,607 @@
+package cella
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Positions in the 3x3 neighbourhood as {y, x}
+var (
+	posC  = [2]int{1, 1}
+	posN  = [2]int{0, 1}
+	posNE = [2]int{0, 2}
+	posE  = [2]int{1, 2}
+	posSE = [2]int{2, 2}
+	posS  = [2]int{2, 1}
+	posSW = [2]int{2, 0}
+	posW  = [2]int{1, 0}
+	posNW = [2]int{0, 0}
+)
+
+// Order of the cells in the transitions of a @TABLE, excluding the new state
+var (
+	tableOrderMoore      = [][2]int{posC, posN, posNE, posE, posSE, posS, posSW, posW, posNW}
+	tableOrderVonNeumann = [][2]int{posC, posN, posE, posS, posW}
+)
+
+// Order of the cells visited in a @TREE
+var (
+	treeOrderMoore      = [][2]int{posNW, posNE, posSW, posSE, posN, posW, posE, posS, posC}
+	treeOrderVonNeumann = [][2]int{posN, posW, posE, posS, posC}
+)
+
+// GollyRule is a rule loaded from a Golly RuleLoader .rule file,
+// defined either by a @TABLE of transitions or by a @TREE
+type GollyRule struct {
+	Name          string              // Name of the rule given in @RULE
+	NumStates     int                 // Number of states
+	Neighbourhood Neighbourhood       // Neighbourhood of the rule
+	Colors        map[Cell]color.RGBA // Colors of the states given in @COLORS
+	table         *gollyTable         // Transitions of a @TABLE
+	tree          *gollyTree          // Decision tree of a @TREE
+	cache         map[[9]Cell]gollyResult
+}
+
+// gollyResult is the cached result of a neighbourhood
+type gollyResult struct {
+	state Cell
+	ok    bool
+}
+
+// maxGollyCache limits the number of cached neighbourhoods
+const maxGollyCache = 1 << 20
+
+// LoadGollyRuleFile reads a Golly .rule file
+func LoadGollyRuleFile(path string) (*GollyRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadGollyRule(f)
+}
+
+// LoadGollyRule reads a Golly RuleLoader file with a @TABLE or a @TREE section.
+// Only the Moore and von Neumann neighbourhoods are supported.
+// The @COLORS section is read, other sections like @ICONS are ignored.
+func LoadGollyRule(r io.Reader) (*GollyRule, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	g := &GollyRule{Colors: make(map[Cell]color.RGBA), cache: make(map[[9]Cell]gollyResult)}
+	section := ""
+	var tableLines, treeLines []gollyLine
+	line := 0
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "@") {
+			fields := strings.Fields(text)
+			section = fields[0]
+			if section == "@RULE" && len(fields) > 1 {
+				g.Name = fields[1]
+			}
+			continue
+		}
+		switch section {
+		case "@TABLE":
+			tableLines = append(tableLines, gollyLine{line, text})
+		case "@TREE":
+			treeLines = append(treeLines, gollyLine{line, text})
+		case "@COLORS":
+			if err := g.parseColor(line, text); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("golly: %w", err)
+	}
+	var err error
+	switch {
+	case len(tableLines) > 0:
+		g.table, err = parseGollyTable(g, tableLines)
+	case len(treeLines) > 0:
+		g.tree, err = parseGollyTree(g, treeLines)
+	default:
+		return nil, fmt.Errorf("golly: missing @TABLE or @TREE section")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for s := range g.Colors {
+		if int(s) >= g.NumStates {
+			return nil, fmt.Errorf("golly: @COLORS: state %d out of range [0, %d)", s, g.NumStates)
+		}
+	}
+	return g, nil
+}
+
+// gollyLine is a line of a section with its line number
+type gollyLine struct {
+	num  int
+	text string
+}
+
+// parseColor parses a "state r g b" line of the @COLORS section
+func (g *GollyRule) parseColor(line int, text string) error {
+	fields := strings.Fields(strings.NewReplacer(",", " ").Replace(text))
+	if len(fields) != 4 {
+		// Gradients "r g b r g b" are ignored
+		return nil
+	}
+	var v [4]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n > 255 {
+			return fmt.Errorf("golly: line %d: invalid color %q", line, text)
+		}
+		v[i] = n
+	}
+	g.Colors[Cell(v[0])] = color.RGBA{uint8(v[1]), uint8(v[2]), uint8(v[3]), 0xff}
+	return nil
+}
+
+// Next returns the new state of the center cell of the 3x3 neighbourhood.
+// The second value is false if no transition matched, in which case
+// the cell keeps its state.
+func (g *GollyRule) Next(neighbours [][]Cell) (Cell, bool) {
+	var key [9]Cell
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			key[y*3+x] = neighbours[y][x]
+		}
+	}
+	if res, ok := g.cache[key]; ok {
+		return res.state, res.ok
+	}
+	var res gollyResult
+	if g.table != nil {
+		res.state, res.ok = g.table.next(&key)
+	} else {
+		res.state, res.ok = g.tree.next(&key)
+	}
+	if len(g.cache) >= maxGollyCache {
+		g.cache = make(map[[9]Cell]gollyResult)
+	}
+	g.cache[key] = res
+	return res.state, res.ok
+}
+
+// Copy returns a copy of the rule with its own cache of neighbourhoods, so
+// the rules of the copy and of the original can be used concurrently.
+// The transitions are shared since they do not change.
+func (g *GollyRule) Copy() *GollyRule {
+	cp := *g
+	cp.cache = make(map[[9]Cell]gollyResult)
+	return &cp
+}
+
+// Rules creates the rules to be used in a Cella2d with NumStates states.
+// There is one rule for each new state. The rules are not safe for
+// concurrent use, each automaton needs its own GollyRule.
+func (g *GollyRule) Rules() []*Rule2d {
+	rules := make([]*Rule2d, g.NumStates)
+	for s := range rules {
+		state := Cell(s)
+		rules[s] = NewRule2dFunc(fmt.Sprintf("golly %s -> %d", g.Name, s), state, func(neighbours [][]Cell) bool {
+			next, ok := g.Next(neighbours)
+			return ok && next == state
+		})
+	}
+	return rules
+}
+
+// parseGollyNeighbourhood parses the neighbourhood of a @TABLE or a @TREE
+func parseGollyNeighbourhood(name string) (Neighbourhood, error) {
+	switch strings.ToLower(name) {
+	case "moore":
+		return NeighbourhoodMoore, nil
+	case "vonneumann":
+		return NeighbourhoodVonNeumann, nil
+	}
+	return 0, fmt.Errorf("unsupported neighbourhood %q", name)
+}
+
+// gollyTable is the set of transitions of a @TABLE
+type gollyTable struct {
+	numStates   int
+	order       [][2]int          // Positions of the inputs of a transition
+	symmetries  [][]int           // Permutations of the inputs, used unless permute is set
+	permute     bool              // Any permutation of the neighbours matches
+	transitions []gollyTransition // Transitions in file order
+}
+
+// gollyTransition is a transition of a @TABLE.
+// Each input is a set of allowed states, inputs sharing a variable
+// must take the same value.
+type gollyTransition struct {
+	inputs [][]bool // Allowed states of each input
+	vars   []int    // First input using the variable of each input, -1 for literals
+	output Cell     // New state if outVar is -1
+	outVar int      // Input whose value is the new state, -1 for literals
+}
+
+// parseGollyTable parses the lines of a @TABLE section
+func parseGollyTable(g *GollyRule, lines []gollyLine) (*gollyTable, error) {
+	t := new(gollyTable)
+	vars := make(map[string][]Cell)
+	symmetries := "none"
+	neighbourhood := ""
+	for _, l := range lines {
+		if key, value, ok := strings.Cut(l.text, ":"); ok && !strings.HasPrefix(l.text, "var") {
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "n_states":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 2 || n > 256 {
+					return nil, fmt.Errorf("golly: line %d: invalid n_states %q", l.num, value)
+				}
+				t.numStates = n
+			case "neighborhood":
+				neighbourhood = value
+			case "symmetries":
+				symmetries = value
+			default:
+				return nil, fmt.Errorf("golly: line %d: unknown setting %q", l.num, key)
+			}
+			continue
+		}
+		if t.numStates == 0 || neighbourhood == "" {
+			return nil, fmt.Errorf("golly: line %d: n_states and neighborhood must be set before the transitions", l.num)
+		}
+		if t.order == nil {
+			n, err := parseGollyNeighbourhood(neighbourhood)
+			if err != nil {
+				return nil, fmt.Errorf("golly: %w", err)
+			}
+			g.NumStates = t.numStates
+			g.Neighbourhood = n
+			t.order = tableOrderMoore
+			if n == NeighbourhoodVonNeumann {
+				t.order = tableOrderVonNeumann
+			}
+			if err := t.setSymmetries(symmetries); err != nil {
+				return nil, fmt.Errorf("golly: line %d: %w", l.num, err)
+			}
+		}
+		if strings.HasPrefix(l.text, "var") {
+			name, values, err := t.parseVar(l.text[3:], vars)
+			if err != nil {
+				return nil, fmt.Errorf("golly: line %d: %w", l.num, err)
+			}
+			vars[name] = values
+			continue
+		}
+		tr, err := t.parseTransition(l.text, vars)
+		if err != nil {
+			return nil, fmt.Errorf("golly: line %d: %w", l.num, err)
+		}
+		t.transitions = append(t.transitions, tr)
+	}
+	if t.order == nil {
+		return nil, fmt.Errorf("golly: @TABLE has no transitions")
+	}
+	return t, nil
+}
+
+// setSymmetries sets the permutations of the inputs given by the symmetries
+func (t *gollyTable) setSymmetries(name string) error {
+	// Rotations and reflections of the neighbours in clockwise order
+	ring := len(t.order) - 1
+	rotate := func(p []int, n int) []int {
+		q := make([]int, len(p))
+		q[0] = p[0]
+		for i := 1; i <= ring; i++ {
+			q[i] = p[1+(i-1+n)%ring]
+		}
+		return q
+	}
+	reflect := func(p []int) []int {
+		// Mirror left and right, keeping north in place
+		q := make([]int, len(p))
+		q[0] = p[0]
+		for i := 1; i <= ring; i++ {
+			q[i] = p[1+(ring-(i-1))%ring]
+		}
+		return q
+	}
+	identity := make([]int, len(t.order))
+	for i := range identity {
+		identity[i] = i
+	}
+	step := ring / 4
+	var perms [][]int
+	switch name {
+	case "none":
+		perms = [][]int{identity}
+	case "rotate2":
+		perms = [][]int{identity, rotate(identity, 2*step)}
+	case "rotate4":
+		for i := 0; i < 4; i++ {
+			perms = append(perms, rotate(identity, i*step))
+		}
+	case "rotate8":
+		if ring != 8 {
+			return fmt.Errorf("rotate8 needs the Moore neighbourhood")
+		}
+		for i := 0; i < 8; i++ {
+			perms = append(perms, rotate(identity, i))
+		}
+	case "reflect_horizontal":
+		perms = [][]int{identity, reflect(identity)}
+	case "rotate4reflect":
+		for i := 0; i < 4; i++ {
+			perms = append(perms, rotate(identity, i*step), reflect(rotate(identity, i*step)))
+		}
+	case "rotate8reflect":
+		if ring != 8 {
+			return fmt.Errorf("rotate8reflect needs the Moore neighbourhood")
+		}
+		for i := 0; i < 8; i++ {
+			perms = append(perms, rotate(identity, i), reflect(rotate(identity, i)))
+		}
+	case "permute":
+		t.permute = true
+		perms = [][]int{identity}
+	default:
+		return fmt.Errorf("unsupported symmetries %q", name)
+	}
+	t.symmetries = perms
+	return nil
+}
+
+// parseVar parses a "var name={a,b,...}" declaration
+func (t *gollyTable) parseVar(text string, vars map[string][]Cell) (string, []Cell, error) {
+	name, list, ok := strings.Cut(text, "=")
+	name = strings.TrimSpace(name)
+	list = strings.TrimSpace(list)
+	if !ok || name == "" || !strings.HasPrefix(list, "{") || !strings.HasSuffix(list, "}") {
+		return "", nil, fmt.Errorf("invalid variable declaration %q", text)
+	}
+	values := []Cell{}
+	for _, item := range strings.Split(list[1:len(list)-1], ",") {
+		item = strings.TrimSpace(item)
+		if v, ok := vars[item]; ok {
+			values = append(values, v...)
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 0 || n >= t.numStates {
+			return "", nil, fmt.Errorf("invalid value %q in variable %s", item, name)
+		}
+		values = append(values, Cell(n))
+	}
+	return name, values, nil
+}
+
+// parseTransition parses a transition with comma separated inputs
+// or, if all states are digits, written without separators
+func (t *gollyTable) parseTransition(text string, vars map[string][]Cell) (gollyTransition, error) {
+	var items []string
+	if strings.Contains(text, ",") {
+		items = strings.Split(text, ",")
+	} else {
+		for _, ch := range strings.ReplaceAll(text, " ", "") {
+			items = append(items, string(ch))
+		}
+	}
+	if len(items) != len(t.order)+1 {
+		return gollyTransition{}, fmt.Errorf("transition %q has %d states, expected %d", text, len(items), len(t.order)+1)
+	}
+	tr := gollyTransition{
+		inputs: make([][]bool, len(t.order)),
+		vars:   make([]int, len(t.order)),
+		outVar: -1,
+	}
+	firstUse := make(map[string]int)
+	for i, item := range items[:len(t.order)] {
+		item = strings.TrimSpace(item)
+		tr.inputs[i] = make([]bool, t.numStates)
+		tr.vars[i] = -1
+		if values, ok := vars[item]; ok {
+			for _, v := range values {
+				tr.inputs[i][v] = true
+			}
+			if first, ok := firstUse[item]; ok {
+				tr.vars[i] = first
+			} else {
+				firstUse[item] = i
+				tr.vars[i] = i
+			}
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 0 || n >= t.numStates {
+			return gollyTransition{}, fmt.Errorf("invalid state %q", item)
+		}
+		tr.inputs[i][n] = true
+	}
+	out := strings.TrimSpace(items[len(t.order)])
+	if first, ok := firstUse[out]; ok {
+		tr.outVar = first
+		return tr, nil
+	}
+	n, err := strconv.Atoi(out)
+	if err != nil || n < 0 || n >= t.numStates {
+		return gollyTransition{}, fmt.Errorf("invalid new state %q", out)
+	}
+	tr.output = Cell(n)
+	return tr, nil
+}
+
+// next returns the new state of the neighbourhood given as 3x3 cells in row order
+func (t *gollyTable) next(key *[9]Cell) (Cell, bool) {
+	values := make([]Cell, len(t.order))
+	for i, p := range t.order {
+		values[i] = key[p[0]*3+p[1]]
+	}
+	permuted := make([]Cell, len(values))
+	for _, tr := range t.transitions {
+		if t.permute {
+			if out, ok := tr.matchPermute(values); ok {
+				return out, true
+			}
+			continue
+		}
+		for _, perm := range t.symmetries {
+			for i, p := range perm {
+				permuted[i] = values[p]
+			}
+			if out, ok := tr.match(permuted); ok {
+				return out, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// match checks if the inputs match the transition and returns the new state
+func (tr *gollyTransition) match(values []Cell) (Cell, bool) {
+	for i, v := range values {
+		if int(v) >= len(tr.inputs[i]) || !tr.inputs[i][v] {
+			return 0, false
+		}
+		if tr.vars[i] >= 0 && tr.vars[i] != i && values[tr.vars[i]] != v {
+			return 0, false
+		}
+	}
+	if tr.outVar >= 0 {
+		return values[tr.outVar], true
+	}
+	return tr.output, true
+}
+
+// matchPermute checks if any permutation of the neighbours matches the
+// transition, keeping the center cell in place
+func (tr *gollyTransition) matchPermute(values []Cell) (Cell, bool) {
+	assigned := make([]Cell, len(values))
+	assigned[0] = values[0]
+	used := make([]bool, len(values))
+	var assign func(i int) (Cell, bool)
+	assign = func(i int) (Cell, bool) {
+		if i == len(values) {
+			return tr.match(assigned)
+		}
+		for j := 1; j < len(values); j++ {
+			if used[j] || int(values[j]) >= len(tr.inputs[i]) || !tr.inputs[i][values[j]] {
+				continue
+			}
+			used[j] = true
+			assigned[i] = values[j]
+			out, ok := assign(i + 1)
+			used[j] = false
+			if ok {
+				return out, true
+			}
+		}
+		return 0, false
+	}
+	return assign(1)
+}
+
+// gollyTree is the decision tree of a @TREE
+type gollyTree struct {
+	order [][2]int // Positions visited from the root to the leaves
+	nodes [][]int  // Children of each node, states for nodes of level 1
+	root  int      // Index of the root node
+}
+
+// parseGollyTree parses the lines of a @TREE section
+func parseGollyTree(g *GollyRule, lines []gollyLine) (*gollyTree, error) {
+	t := new(gollyTree)
+	numStates, numNeighbours, numNodes := 0, 0, -1
+	levels := []int{}
+	for _, l := range lines {
+		if key, value, ok := strings.Cut(l.text, "="); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("golly: line %d: invalid value %q", l.num, value)
+			}
+			switch strings.TrimSpace(key) {
+			case "num_states":
+				numStates = n
+			case "num_neighbors":
+				numNeighbours = n
+			case "num_nodes":
+				numNodes = n
+			default:
+				return nil, fmt.Errorf("golly: line %d: unknown setting %q", l.num, key)
+			}
+			continue
+		}
+		if numStates < 2 || numStates > 256 {
+			return nil, fmt.Errorf("golly: line %d: invalid num_states %d", l.num, numStates)
+		}
+		fields := strings.Fields(l.text)
+		if len(fields) != numStates+1 {
+			return nil, fmt.Errorf("golly: line %d: node has %d values, expected %d", l.num, len(fields), numStates+1)
+		}
+		level, err := strconv.Atoi(fields[0])
+		if err != nil || level < 1 {
+			return nil, fmt.Errorf("golly: line %d: invalid level %q", l.num, fields[0])
+		}
+		children := make([]int, numStates)
+		for i, f := range fields[1:] {
+			n, err := strconv.Atoi(f)
+			valid := err == nil && n >= 0
+			if level == 1 {
+				valid = valid && n < numStates
+			} else {
+				valid = valid && n < len(t.nodes) && levels[n] == level-1
+			}
+			if !valid {
+				return nil, fmt.Errorf("golly: line %d: invalid value %q", l.num, f)
+			}
+			children[i] = n
+		}
+		t.nodes = append(t.nodes, children)
+		levels = append(levels, level)
+	}
+	switch numNeighbours {
+	case 8:
+		g.Neighbourhood = NeighbourhoodMoore
+		t.order = treeOrderMoore
+	case 4:
+		g.Neighbourhood = NeighbourhoodVonNeumann
+		t.order = treeOrderVonNeumann
+	default:
+		return nil, fmt.Errorf("golly: unsupported num_neighbors %d", numNeighbours)
+	}
+	if len(t.nodes) == 0 {
+		return nil, fmt.Errorf("golly: @TREE has no nodes")
+	}
+	if numNodes >= 0 && numNodes != len(t.nodes) {
+		return nil, fmt.Errorf("golly: @TREE has %d nodes, expected %d", len(t.nodes), numNodes)
+	}
+	t.root = len(t.nodes) - 1
+	if levels[t.root] != len(t.order) {
+		return nil, fmt.Errorf("golly: @TREE root has level %d, expected %d", levels[t.root], len(t.order))
+	}
+	g.NumStates = numStates
+	return t, nil
+}
+
+// next returns the new state of the neighbourhood given as 3x3 cells in row order
+func (t *gollyTree) next(key *[9]Cell) (Cell, bool) {
+	node := t.root
+	for _, p := range t.order {
+		v := int(key[p[0]*3+p[1]])
+		if v >= len(t.nodes[node]) {
+			return 0, false
+		}
+		node = t.nodes[node][v]
+	}
+	return Cell(node), true
+}
@@ -0,0 +1,119 @@
+package cella
+
+import (
+	"strings"
+	"testing"
+)
+
+const wireWorldTable = `@RULE WireWorld
+@TABLE
+n_states:4
+neighborhood:Moore
+symmetries:permute
+var a={0,1,2,3}
+var b={0,1,2,3}
+var c={0,1,2,3}
+var d={0,1,2,3}
+var e={0,1,2,3}
+var f={0,1,2,3}
+var g={0,1,2,3}
+var h={0,1,2,3}
+var i={0,2,3}
+var j={0,2,3}
+var k={0,2,3}
+var l={0,2,3}
+var m={0,2,3}
+var n={0,2,3}
+var o={0,2,3}
+1,a,b,c,d,e,f,g,h,2
+2,a,b,c,d,e,f,g,h,3
+3,1,i,j,k,l,m,n,o,1
+3,1,1,i,j,k,l,m,n,1
+@COLORS
+1 255 255 0
+`
+
+func TestGollyTableWireWorld(t *testing.T) {
+	rule, err := LoadGollyRule(strings.NewReader(wireWorldTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Name != "WireWorld" || rule.NumStates != 4 || rule.Colors[1].G != 255 {
+		t.Fatalf("WireWorld rule does not match: %+v", rule)
+	}
+	ca := NewCella2d(5, 3, rule.NumStates)
+	ca.SetInitGrid(NewGrid(5, 3))
+	ca.SetNextGrid(NewGrid(5, 3))
+	ca.SetRules(rule.Rules())
+	ca.InitGrid.SetCell(0, 1, 1)
+	for x := 1; x < 5; x++ {
+		ca.InitGrid.SetCell(x, 1, 3)
+	}
+	// The electron moves along the wire
+	expected := [][]Cell{{2, 1, 3, 3, 3}, {3, 2, 1, 3, 3}, {3, 3, 2, 1, 3}}
+	for _, row := range expected {
+		if err := ca.NextGeneration(); err != nil {
+			t.Fatal(err)
+		}
+		ca.InitGrid, ca.NextGrid = ca.NextGrid, ca.InitGrid
+		for x, state := range row {
+			if ca.InitGrid.GetCell(x, 1) != state {
+				t.Fatalf("WireWorld generation %d: cell %d is %d, expected %d", ca.Generation, x, ca.InitGrid.GetCell(x, 1), state)
+			}
+		}
+	}
+}
+
+func TestGollyRuleCopy(t *testing.T) {
+	rule, err := LoadGollyRule(strings.NewReader(wireWorldTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := rule.Copy()
+	// A conductor next to an electron head becomes a head
+	if s, ok := cp.Next([][]Cell{{0, 0, 0}, {1, 3, 0}, {0, 0, 0}}); !ok || s != 1 {
+		t.Fatalf("Copied rule returned %d, %v", s, ok)
+	}
+	if len(rule.cache) != 0 || len(cp.cache) != 1 {
+		t.Fatal("Copied rule shares its cache")
+	}
+}
+
+func TestGollyTableSymmetries(t *testing.T) {
+	rule, err := LoadGollyRule(strings.NewReader("@RULE Test\n@TABLE\nn_states:2\nneighborhood:vonNeumann\nsymmetries:rotate4\n010001\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	east := [][]Cell{{0, 0, 0}, {0, 0, 1}, {0, 0, 0}}
+	if state, ok := rule.Next(east); !ok || state != 1 {
+		t.Fatal("Rotated transition does not match")
+	}
+	two := [][]Cell{{0, 1, 0}, {0, 0, 1}, {0, 0, 0}}
+	if _, ok := rule.Next(two); ok {
+		t.Fatal("Transition should not match")
+	}
+}
+
+func TestGollyTree(t *testing.T) {
+	// Each cell takes the state of its north neighbour
+	tree := "@RULE Down\n@TREE\nnum_states=2\nnum_neighbors=4\nnum_nodes=9\n" +
+		"1 0 0\n1 1 1\n2 0 0\n2 1 1\n3 2 2\n3 3 3\n4 4 4\n4 5 5\n5 6 7\n"
+	rule, err := LoadGollyRule(strings.NewReader(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := NewCella2d(3, 3, 2)
+	ca.SetInitGrid(NewGrid(3, 3))
+	ca.SetNextGrid(NewGrid(3, 3))
+	ca.SetRules(rule.Rules())
+	ca.InitGrid.SetCell(1, 0, 1)
+	if err := ca.NextGeneration(); err != nil {
+		t.Fatal(err)
+	}
+	if ca.NextGrid.GetCell(1, 1) != 1 || ca.NextGrid.GetCell(1, 0) != 0 {
+		t.Fatal("Tree rule does not move the cell down")
+	}
+	if _, err := LoadGollyRule(strings.NewReader("@RULE Bad\n@TREE\nnum_states=2\nnum_neighbors=4\n1 0 3\n")); err == nil {
+		t.Fatal("Invalid tree should send an error")
+	}
+}
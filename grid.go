@@ -30,16 +30,80 @@ func NewGrid(Width, Height int) *Grid {
 	return g
 }
 
-// Set sets a cell state in the grid
+// Clone returns a copy of the grid, auxiliar borders included
+func (g *Grid) Clone() *Grid {
+	c := NewGrid(g.Width, g.Height)
+	for y, row := range g.WholeGrid {
+		copy(c.WholeGrid[y], row)
+	}
+	return c
+}
+
+// Set sets a cell state in the grid.
+// It panics if the position is outside of the grid, see SetCellChecked.
 func (g *Grid) SetCell(x, y int, c Cell) {
 	g.Cells[y][x] = c
 }
 
-// Get gets a cell state in the grid
+// Get gets a cell state in the grid.
+// It panics if the position is outside of the grid, see GetCellChecked.
 func (g *Grid) GetCell(x, y int) Cell {
 	return g.Cells[y][x]
 }
 
+// InBounds reports if the position is inside of the grid
+func (g *Grid) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+// SetCellChecked sets a cell state in the grid, an OutOfBoundsError is
+// returned if the position is outside of the grid
+func (g *Grid) SetCellChecked(x, y int, c Cell) error {
+	if !g.InBounds(x, y) {
+		return &OutOfBoundsError{X: x, Y: y, Width: g.Width, Height: g.Height}
+	}
+	g.Cells[y][x] = c
+	return nil
+}
+
+// GetCellChecked gets a cell state in the grid, an OutOfBoundsError is
+// returned if the position is outside of the grid
+func (g *Grid) GetCellChecked(x, y int) (Cell, error) {
+	if !g.InBounds(x, y) {
+		return 0, &OutOfBoundsError{X: x, Y: y, Width: g.Width, Height: g.Height}
+	}
+	return g.Cells[y][x], nil
+}
+
+// CheckStates checks that all the cells, auxiliar borders included, are
+// in a state lower than numStates. An InvalidStateError is returned for
+// the first cell in row order that is not.
+func (g *Grid) CheckStates(numStates int) error {
+	return g.checkStates(numStates, false)
+}
+
+// checkBorderStates checks the states of the auxiliar borders only
+func (g *Grid) checkBorderStates(numStates int) error {
+	return g.checkStates(numStates, true)
+}
+
+// checkStates checks the states of the cells of the whole grid, only
+// those of the auxiliar borders if bordersOnly is set
+func (g *Grid) checkStates(numStates int, bordersOnly bool) error {
+	for y, row := range g.WholeGrid {
+		step := 1
+		if bordersOnly && y > 0 && y <= g.Height {
+			step = g.Width + 1
+		}
+		for x := 0; x < len(row); x += step {
+			if s := row[x]; int(s) >= numStates {
+				return &InvalidStateError{X: x - 1, Y: y - 1, State: s, NumStates: numStates}
+			}
+		}
+	}
+	return nil
+}
+
 // SetAuxBorderLeft sets a left auxiliar border of the grid
 // used for the evaluation of the rules
 func (g *Grid) SetAuxBorderLeft(bl []Cell) {
@@ -0,0 +1,282 @@
+package cella
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FNV-1a constants used to hash grids
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Hash returns a 64 bit FNV-1a hash of the size and the cells of the grid.
+// The auxiliar borders are not included.
+func (g *Grid) Hash() uint64 {
+	h := uint64(fnvOffset64)
+	for _, v := range [2]int{g.Width, g.Height} {
+		for i := 0; i < 4; i++ {
+			h ^= uint64(byte(v >> (8 * i)))
+			h *= fnvPrime64
+		}
+	}
+	for _, row := range g.Cells {
+		for _, c := range row {
+			h ^= uint64(c)
+			h *= fnvPrime64
+		}
+	}
+	return h
+}
+
+// BoundingBox returns the smallest rectangle containing the cells that are
+// not in state 0. The last value is false if all the cells are in state 0.
+func (g *Grid) BoundingBox() (x, y, w, h int, ok bool) {
+	minX, minY, maxX, maxY := g.Width, g.Height, -1, -1
+	for cy, row := range g.Cells {
+		for cx, c := range row {
+			if c == 0 {
+				continue
+			}
+			if cx < minX {
+				minX = cx
+			}
+			if cx > maxX {
+				maxX = cx
+			}
+			if cy < minY {
+				minY = cy
+			}
+			if cy > maxY {
+				maxY = cy
+			}
+		}
+	}
+	if maxX < 0 {
+		return 0, 0, 0, 0, false
+	}
+	return minX, minY, maxX - minX + 1, maxY - minY + 1, true
+}
+
+// Trim returns a new grid with the cells inside the bounding box.
+// It returns nil if all the cells are in state 0.
+func (g *Grid) Trim() *Grid {
+	x, y, w, h, ok := g.BoundingBox()
+	if !ok {
+		return nil
+	}
+	return g.SubGrid(x, y, w, h)
+}
+
+// orientations returns the 8 rotations and reflections of the grid
+func (g *Grid) orientations() []*Grid {
+	grids := make([]*Grid, 0, 8)
+	cur := g
+	for i := 0; i < 4; i++ {
+		grids = append(grids, cur, cur.Transpose())
+		cur = cur.Rotate90()
+	}
+	return grids
+}
+
+// lessGrid orders grids by size and then by their cells in row order
+func lessGrid(a, b *Grid) bool {
+	if a.Height != b.Height {
+		return a.Height < b.Height
+	}
+	if a.Width != b.Width {
+		return a.Width < b.Width
+	}
+	for y := range a.Cells {
+		for x := range a.Cells[y] {
+			if a.Cells[y][x] != b.Cells[y][x] {
+				return a.Cells[y][x] < b.Cells[y][x]
+			}
+		}
+	}
+	return false
+}
+
+// Canonical returns the trimmed grid in a form that does not change under
+// translation, rotation and reflection: the least of its 8 orientations.
+// It returns nil if all the cells are in state 0.
+func (g *Grid) Canonical() *Grid {
+	t := g.Trim()
+	if t == nil {
+		return nil
+	}
+	best := t
+	for _, o := range t.orientations()[1:] {
+		if lessGrid(o, best) {
+			best = o
+		}
+	}
+	return best
+}
+
+// CanonicalHash returns the hash of the canonical form of the grid,
+// equal for all translations, rotations and reflections of a pattern
+func (g *Grid) CanonicalHash() uint64 {
+	c := g.Canonical()
+	if c == nil {
+		return NewGrid(1, 1).Hash()
+	}
+	return c.Hash()
+}
+
+// wechslerDigits are the characters of the extended Wechsler format
+const wechslerDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// Wechsler returns the extended Wechsler format of the trimmed grid, as
+// used by apgcodes. Cells not in state 0 are considered alive.
+// The grid is split in strips of 5 rows separated by 'z', each column of a
+// strip is a base 32 digit and runs of zeros are compressed with 'w', 'x' and 'y'.
+func (g *Grid) Wechsler() string {
+	t := g.Trim()
+	if t == nil {
+		return "0"
+	}
+	var sb strings.Builder
+	for strip := 0; strip < t.Height; strip += 5 {
+		if strip > 0 {
+			sb.WriteByte('z')
+		}
+		zeros := 0
+		for x := 0; x < t.Width; x++ {
+			v := 0
+			for bit := 0; bit < 5 && strip+bit < t.Height; bit++ {
+				if t.Cells[strip+bit][x] != 0 {
+					v |= 1 << bit
+				}
+			}
+			if v == 0 {
+				zeros++
+				continue
+			}
+			writeWechslerZeros(&sb, zeros)
+			zeros = 0
+			sb.WriteByte(wechslerDigits[v])
+		}
+		// Trailing zeros of a strip are omitted
+	}
+	return sb.String()
+}
+
+// writeWechslerZeros writes a run of zero columns
+func writeWechslerZeros(sb *strings.Builder, n int) {
+	for n > 0 {
+		switch {
+		case n == 1:
+			sb.WriteByte('0')
+			n = 0
+		case n == 2:
+			sb.WriteByte('w')
+			n = 0
+		case n == 3:
+			sb.WriteByte('x')
+			n = 0
+		default:
+			k := n - 4
+			if k > 35 {
+				k = 35
+			}
+			sb.WriteByte('y')
+			sb.WriteByte(wechslerDigits[k])
+			n -= k + 4
+		}
+	}
+}
+
+// canonicalWechsler returns the shortest, and then least, extended Wechsler
+// format among the 8 orientations of the grid
+func canonicalWechsler(g *Grid) string {
+	t := g.Trim()
+	if t == nil {
+		return "0"
+	}
+	best := ""
+	for _, o := range t.orientations() {
+		if w := o.Wechsler(); best == "" || lessWechsler(w, best) {
+			best = w
+		}
+	}
+	return best
+}
+
+// lessWechsler orders codes by length and then alphabetically
+func lessWechsler(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// PatternNames are the common names of small patterns of Conway's Game of
+// Life indexed by their apgcode
+var PatternNames = map[string]string{
+	"xs4_33":   "block",
+	"xs4_252":  "tub",
+	"xs5_253":  "boat",
+	"xs6_696":  "beehive",
+	"xs6_356":  "ship",
+	"xs7_2596": "loaf",
+	"xs8_6996": "pond",
+	"xp2_7":    "blinker",
+	"xp2_7e":   "toad",
+	"xp2_318c": "beacon",
+	"xq4_153":  "glider",
+	"xq4_6frc": "lightweight spaceship",
+	"xs0_0":    "empty",
+	"xs7_178c": "eater 1",
+}
+
+// Apgcode runs the period analysis of the automaton and returns an
+// apgcode-style identifier of its pattern: "xs<population>_" for still
+// lifes, "xp<period>_" for oscillators and "xq<period>_" for spaceships,
+// followed by the canonical extended Wechsler format of the pattern over all
+// its phases and orientations. Cells not in state 0 are considered alive.
+// An empty string is returned if no period was found.
+// The automaton is left one period after the end of the analysis.
+func Apgcode(c *Cella2d, opts AnalyzeOptions) (string, *PeriodResult, error) {
+	res, err := Analyze(c, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	var prefix string
+	switch res.Kind {
+	case KindUnknown:
+		return "", res, nil
+	case KindEmpty:
+		return "xs0_0", res, nil
+	case KindStillLife:
+		prefix = fmt.Sprintf("xs%d_", c.Width*c.Height-countState(c.InitGrid, 0))
+	case KindOscillator:
+		prefix = fmt.Sprintf("xp%d_", res.Period)
+	case KindSpaceship:
+		prefix = fmt.Sprintf("xq%d_", res.Period)
+	}
+	best := ""
+	for i := 0; i < res.Period; i++ {
+		if w := canonicalWechsler(c.InitGrid); best == "" || lessWechsler(w, best) {
+			best = w
+		}
+		if err := c.Step(); err != nil {
+			return "", nil, err
+		}
+	}
+	return prefix + best, res, nil
+}
+
+// countState returns the number of cells in the given state
+func countState(g *Grid, state Cell) int {
+	n := 0
+	for _, row := range g.Cells {
+		for _, c := range row {
+			if c == state {
+				n++
+			}
+		}
+	}
+	return n
+}
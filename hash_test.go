@@ -0,0 +1,71 @@
+package cella
+
+import (
+	"testing"
+)
+
+func TestGridHash(t *testing.T) {
+	a := NewGrid(5, 5)
+	b := NewGrid(5, 5)
+	if a.Hash() != b.Hash() {
+		t.Fatal("Equal grids have different hashes")
+	}
+	a.SetCell(2, 3, 1)
+	if a.Hash() == b.Hash() {
+		t.Fatal("Different grids have the same hash")
+	}
+	if NewGrid(5, 4).Hash() == NewGrid(4, 5).Hash() {
+		t.Fatal("Grids of different size have the same hash")
+	}
+}
+
+func TestGridCanonical(t *testing.T) {
+	// L-tromino in two orientations and positions
+	a := NewGrid(6, 6)
+	a.SetCell(1, 1, 1)
+	a.SetCell(1, 2, 1)
+	a.SetCell(2, 2, 1)
+	b := NewGrid(8, 4)
+	b.SetCell(5, 0, 1)
+	b.SetCell(6, 0, 1)
+	b.SetCell(5, 1, 1)
+	if x, y, w, h, ok := a.BoundingBox(); !ok || x != 1 || y != 1 || w != 2 || h != 2 {
+		t.Fatalf("Bounding box is (%d, %d) %dx%d", x, y, w, h)
+	}
+	if a.Hash() == b.Hash() || a.CanonicalHash() != b.CanonicalHash() {
+		t.Fatal("Canonical forms of the same pattern differ")
+	}
+	if !EqualsGrid(a.Canonical(), b.Canonical()) {
+		t.Fatal("Canonical grids differ")
+	}
+	if NewGrid(3, 3).Trim() != nil {
+		t.Fatal("Empty grid should not be trimmed")
+	}
+}
+
+func TestApgcode(t *testing.T) {
+	patterns := map[string][]string{
+		"block":                 {"**", "**"},
+		"blinker":               {"***"},
+		"glider":                {".*.", "..*", "***"},
+		"beacon":                {"**..", "**..", "..**", "..**"},
+		"lightweight spaceship": {".*..*", "*....", "*...*", "****."},
+	}
+	for name, rows := range patterns {
+		ca := newGameOfLife(20, 20)
+		for y, row := range rows {
+			for x, ch := range row {
+				if ch == '*' {
+					ca.InitGrid.SetCell(8+x, 8+y, 1)
+				}
+			}
+		}
+		code, _, err := Apgcode(ca, AnalyzeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if PatternNames[code] != name {
+			t.Fatalf("Apgcode of %s is %s", name, code)
+		}
+	}
+}
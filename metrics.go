@@ -0,0 +1,110 @@
+package cella
+
+import (
+	"fmt"
+	"math"
+)
+
+// RuleMetrics are statistics of the transition function of a rule set,
+// taken over every neighbourhood as equally likely
+type RuleMetrics struct {
+	// Lambda is Langton's lambda: the fraction of neighbourhoods whose new
+	// state is not the quiescent state 0
+	Lambda float64
+	// Z is the greatest ZPositions, Wuensche's Z parameter generalized to
+	// the 3x3 neighbourhood and taking only the full neighbourhoods
+	Z float64
+	// ZPositions is the probability, for each position of the neighbourhood
+	// in row order, that its state can be deduced from the other cells and
+	// the new state
+	ZPositions [9]float64
+	// Sensitivity is the probability that changing a cell of the
+	// neighbourhood to another state changes the new state
+	Sensitivity float64
+	// Entropy is the Shannon entropy in bits of the new states
+	Entropy float64
+	// States is the fraction of neighbourhoods that go to each state
+	States []float64
+}
+
+// Metrics returns the statistics of the transition function
+func (t *TransitionTable) Metrics() *RuleMetrics {
+	n := t.NumStates
+	size := len(t.Next)
+	m := &RuleMetrics{States: make([]float64, n)}
+	for _, next := range t.Next {
+		if int(next) < n {
+			m.States[next]++
+		}
+	}
+	for s := range m.States {
+		m.States[s] /= float64(size)
+		if p := m.States[s]; p > 0 {
+			m.Entropy -= p * math.Log2(p)
+		}
+	}
+	m.Lambda = 1 - m.States[0]
+
+	// The neighbourhoods that only differ in a position are i + k*weight
+	// for the states k, starting with the neighbourhood with state 0 there
+	changes, pairs := 0, 0
+	outputs := make([]int, n+1)
+	weight := 1
+	for p := 0; p < 9; p++ {
+		deduced := 0
+		for base := 0; base < size; base++ {
+			if (base/weight)%n != 0 {
+				continue
+			}
+			for s := range outputs {
+				outputs[s] = 0
+			}
+			for k := 0; k < n; k++ {
+				outputs[t.nextIndex(base+k*weight)]++
+			}
+			for k := 0; k < n; k++ {
+				// A completion whose new state is unique identifies the cell
+				if outputs[t.nextIndex(base+k*weight)] == 1 {
+					deduced++
+				}
+				for j := k + 1; j < n; j++ {
+					if t.Next[base+k*weight] != t.Next[base+j*weight] {
+						changes++
+					}
+					pairs++
+				}
+			}
+		}
+		m.ZPositions[p] = float64(deduced) / float64(size)
+		if m.ZPositions[p] > m.Z {
+			m.Z = m.ZPositions[p]
+		}
+		weight *= n
+	}
+	m.Sensitivity = float64(changes) / float64(pairs)
+	return m
+}
+
+// nextIndex returns the new state of a neighbourhood as an index of the
+// states, with states out of range in the last index
+func (t *TransitionTable) nextIndex(i int) int {
+	if s := int(t.Next[i]); s < t.NumStates {
+		return s
+	}
+	return t.NumStates
+}
+
+// String returns a description of the metrics
+func (m *RuleMetrics) String() string {
+	return fmt.Sprintf("lambda %.4f  Z %.4f  sensitivity %.4f  entropy %.4f bits", m.Lambda, m.Z, m.Sensitivity, m.Entropy)
+}
+
+// RuleMetrics returns the statistics of the transition function of the
+// rules of the automaton
+func (c *Cella2d) RuleMetrics() (*RuleMetrics, error) {
+	t, err := NewTransitionTable(c.Rules, c.NumStates)
+	if err != nil {
+		return nil, err
+	}
+	return t.Metrics(), nil
+}
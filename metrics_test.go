@@ -0,0 +1,40 @@
+package cella
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRuleMetrics(t *testing.T) {
+	ca := newGameOfLife(3, 3)
+	m, err := ca.RuleMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 56 births and 84 survivals
+	if m.Lambda != 140.0/512 || m.States[1] != m.Lambda {
+		t.Fatalf("Metrics of Life do not match: %s", m)
+	}
+	if m.ZPositions[0] != m.ZPositions[8] || m.Z <= 0 || m.Z > 1 {
+		t.Fatalf("Z of Life does not match: %v", m.ZPositions)
+	}
+
+	// Without rules every cell keeps its state
+	tab, err := NewTransitionTable(nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = tab.Metrics()
+	if m.Lambda != 0.5 || m.Entropy != 1 || m.Z != 1 || m.ZPositions[4] != 1 || m.ZPositions[0] != 0 ||
+		math.Abs(m.Sensitivity-1.0/9) > 1e-12 {
+		t.Fatalf("Metrics of the identity do not match: %s %v", m, m.ZPositions)
+	}
+
+	tab, err = NewTransitionTable([]*Rule2d{NewRule2d("0 == 0", 0, 3)}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m = tab.Metrics(); m.Lambda != 0 || m.Entropy != 0 || m.Z != 0 || m.Sensitivity != 0 {
+		t.Fatalf("Metrics of the constant rule do not match: %s", m)
+	}
+}
@@ -0,0 +1,297 @@
+package cella
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Connectivity defines which cells belong to the same object
+type Connectivity uint8
+
+const (
+	Connectivity8    Connectivity = iota // Cells touching by a side or a corner
+	Connectivity4                        // Cells touching by a side
+	ConnectivityLife                     // Cells at a distance of up to 2 cells, as in apgsearch
+)
+
+// String returns the name of the connectivity
+func (c Connectivity) String() string {
+	switch c {
+	case Connectivity4:
+		return "4"
+	case ConnectivityLife:
+		return "life"
+	}
+	return "8"
+}
+
+// ParseConnectivity parses the name of a connectivity: "4", "8" or "life"
+func ParseConnectivity(s string) (Connectivity, error) {
+	switch strings.ToLower(s) {
+	case "8", "":
+		return Connectivity8, nil
+	case "4":
+		return Connectivity4, nil
+	case "life":
+		return ConnectivityLife, nil
+	}
+	return 0, fmt.Errorf("objects: unknown connectivity %q", s)
+}
+
+// offsets returns the offsets of the cells connected to a cell
+func (c Connectivity) offsets() [][2]int {
+	var offs [][2]int
+	radius := 1
+	if c == ConnectivityLife {
+		radius = 2
+	}
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			if c == Connectivity4 && dx != 0 && dy != 0 {
+				continue
+			}
+			offs = append(offs, [2]int{dx, dy})
+		}
+	}
+	return offs
+}
+
+// Label labels the connected components of the cells not in state 0.
+// Labels are indexed as labels[y][x], 0 is used for cells in state 0 and
+// the components are numbered from 1 in row order of their first cell.
+// The number of components is returned with the labels.
+func (g *Grid) Label(conn Connectivity) ([][]int, int) {
+	labels := make([][]int, g.Height)
+	for y := range labels {
+		labels[y] = make([]int, g.Width)
+	}
+	offs := conn.offsets()
+	n := 0
+	var stack [][2]int
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.Cells[y][x] == 0 || labels[y][x] != 0 {
+				continue
+			}
+			n++
+			labels[y][x] = n
+			stack = append(stack[:0], [2]int{x, y})
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for _, o := range offs {
+					nx, ny := p[0]+o[0], p[1]+o[1]
+					if nx < 0 || ny < 0 || nx >= g.Width || ny >= g.Height {
+						continue
+					}
+					if g.Cells[ny][nx] == 0 || labels[ny][nx] != 0 {
+						continue
+					}
+					labels[ny][nx] = n
+					stack = append(stack, [2]int{nx, ny})
+				}
+			}
+		}
+	}
+	return labels, n
+}
+
+// Object is a connected component of a grid
+type Object struct {
+	X, Y       int   // Position of the object in the grid
+	Grid       *Grid // Cells of the object, trimmed to its bounding box
+	Population int   // Number of cells of the object not in state 0
+}
+
+// Components extracts the connected components of the cells not in state 0.
+// Each object only contains its own cells, even if the bounding boxes of
+// several objects overlap.
+func (g *Grid) Components(conn Connectivity) []*Object {
+	labels, n := g.Label(conn)
+	if n == 0 {
+		return nil
+	}
+	type box struct{ minX, minY, maxX, maxY int }
+	boxes := make([]box, n)
+	for i := range boxes {
+		boxes[i] = box{g.Width, g.Height, -1, -1}
+	}
+	for y, row := range labels {
+		for x, l := range row {
+			if l == 0 {
+				continue
+			}
+			b := &boxes[l-1]
+			if x < b.minX {
+				b.minX = x
+			}
+			if x > b.maxX {
+				b.maxX = x
+			}
+			if y < b.minY {
+				b.minY = y
+			}
+			if y > b.maxY {
+				b.maxY = y
+			}
+		}
+	}
+	objs := make([]*Object, n)
+	for i, b := range boxes {
+		objs[i] = &Object{X: b.minX, Y: b.minY, Grid: NewGrid(b.maxX-b.minX+1, b.maxY-b.minY+1)}
+	}
+	for y, row := range labels {
+		for x, l := range row {
+			if l == 0 {
+				continue
+			}
+			o := objs[l-1]
+			o.Grid.Cells[y-o.Y][x-o.X] = g.Cells[y][x]
+			o.Population++
+		}
+	}
+	return objs
+}
+
+// CensusOptions are the options to classify objects
+type CensusOptions struct {
+	Connectivity Connectivity   // Connectivity of the objects
+	Padding      int            // Empty cells around an object while it is classified, 16 if not set
+	Analyze      AnalyzeOptions // Options of the period analysis of each object
+}
+
+// Classifier classifies objects running them alone with the rules of an
+// automaton. The apgcodes found are cached by the canonical hash of the
+// objects, so a classifier must not be used by several goroutines.
+type Classifier struct {
+	Rules     []*Rule2d // Rules used to run the objects
+	NumStates int       // Number of states of the rules
+	Options   CensusOptions
+	cache     map[uint64]string
+}
+
+// NewClassifier creates a classifier with the rules of the automaton
+func NewClassifier(c *Cella2d, opts CensusOptions) *Classifier {
+	if opts.Padding <= 0 {
+		opts.Padding = 16
+	}
+	return &Classifier{
+		Rules:     c.Rules,
+		NumStates: c.NumStates,
+		Options:   opts,
+		cache:     make(map[uint64]string),
+	}
+}
+
+// unknownCode is the census key of objects without a period
+const unknownCode = "unknown"
+
+// Classify returns the apgcode of the object running it alone in an
+// empty grid. "unknown" is returned if no period was found.
+func (cl *Classifier) Classify(g *Grid) (string, error) {
+	t := g.Trim()
+	if t == nil {
+		return "xs0_0", nil
+	}
+	h := t.CanonicalHash()
+	if code, ok := cl.cache[h]; ok {
+		return code, nil
+	}
+	pad := cl.Options.Padding
+	c := NewCella2d(t.Width+2*pad, t.Height+2*pad, cl.NumStates)
+	c.SetRules(cl.Rules)
+	init := NewGrid(c.Width, c.Height)
+	init.Paste(t, pad, pad, PasteCopy)
+	c.SetInitGrid(init)
+	c.SetNextGrid(NewGrid(c.Width, c.Height))
+	code, _, err := Apgcode(c, cl.Options.Analyze)
+	if err != nil {
+		return "", err
+	}
+	if code == "" {
+		code = unknownCode
+	}
+	cl.cache[h] = code
+	return code, nil
+}
+
+// Census is the number of objects found indexed by their apgcode
+type Census map[string]int
+
+// Add adds the objects of the grid to the census
+func (cl *Classifier) Add(cs Census, g *Grid) error {
+	for _, o := range g.Components(cl.Options.Connectivity) {
+		code, err := cl.Classify(o.Grid)
+		if err != nil {
+			return err
+		}
+		cs[code]++
+	}
+	return nil
+}
+
+// TakeCensus separates the current grid of the automaton into objects,
+// classifies them and returns the number of objects of each kind
+func TakeCensus(c *Cella2d, opts CensusOptions) (Census, error) {
+	cs := make(Census)
+	if err := NewClassifier(c, opts).Add(cs, c.InitGrid); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Merge adds the counts of another census
+func (cs Census) Merge(other Census) {
+	for code, n := range other {
+		cs[code] += n
+	}
+}
+
+// Total returns the number of objects in the census
+func (cs Census) Total() int {
+	n := 0
+	for _, count := range cs {
+		n += count
+	}
+	return n
+}
+
+// CensusEntry is a kind of object in the census
+type CensusEntry struct {
+	Code  string // Apgcode of the object
+	Name  string // Common name of the object, empty if it is not known
+	Count int    // Number of objects found
+}
+
+// Entries returns the entries of the census sorted by count, from the most
+// common object, and then by apgcode
+func (cs Census) Entries() []CensusEntry {
+	entries := make([]CensusEntry, 0, len(cs))
+	for code, n := range cs {
+		entries = append(entries, CensusEntry{Code: code, Name: PatternNames[code], Count: n})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
+
+// String returns a report of the census with one object per line
+func (cs Census) String() string {
+	var sb strings.Builder
+	for _, e := range cs.Entries() {
+		fmt.Fprintf(&sb, "%8d  %s", e.Count, e.Code)
+		if e.Name != "" {
+			fmt.Fprintf(&sb, " (%s)", e.Name)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
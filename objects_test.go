@@ -0,0 +1,52 @@
+package cella
+
+import (
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	g := NewGrid(8, 4)
+	// Two cells touching by a corner and a cell two columns away
+	g.SetCell(0, 0, 1)
+	g.SetCell(1, 1, 1)
+	g.SetCell(3, 1, 1)
+	for _, test := range []struct {
+		conn Connectivity
+		n    int
+	}{{Connectivity4, 3}, {Connectivity8, 2}, {ConnectivityLife, 1}} {
+		if _, n := g.Label(test.conn); n != test.n {
+			t.Fatalf("Connectivity %s labels %d components, expected %d", test.conn, n, test.n)
+		}
+	}
+	objs := g.Components(Connectivity8)
+	if len(objs) != 2 || objs[0].Population != 2 || objs[0].Grid.Width != 2 ||
+		objs[1].X != 3 || objs[1].Y != 1 || objs[1].Population != 1 {
+		t.Fatal("Components do not match")
+	}
+}
+
+func TestCensus(t *testing.T) {
+	ca := newGameOfLife(30, 12)
+	cells := [][2]int{
+		// Two blocks
+		{1, 1}, {2, 1}, {1, 2}, {2, 2},
+		{1, 8}, {2, 8}, {1, 9}, {2, 9},
+		// Blinker
+		{10, 5}, {11, 5}, {12, 5},
+		// Glider
+		{21, 1}, {22, 2}, {20, 3}, {21, 3}, {22, 3},
+	}
+	for _, c := range cells {
+		ca.InitGrid.SetCell(c[0], c[1], 1)
+	}
+	cs, err := TakeCensus(ca, CensusOptions{Padding: 6, Analyze: AnalyzeOptions{MaxGenerations: 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs["xs4_33"] != 2 || cs["xp2_7"] != 1 || cs["xq4_153"] != 1 || cs.Total() != 4 {
+		t.Fatalf("Census does not match:\n%s", cs)
+	}
+	if e := cs.Entries()[0]; e.Code != "xs4_33" || e.Name != "block" {
+		t.Fatalf("First entry is %v", e)
+	}
+}
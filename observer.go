@@ -0,0 +1,161 @@
+package cella
+
+// Observer is notified of the generations calculated by Step and Run
+type Observer interface {
+	// OnStep is called after each generation. The new generation is in
+	// InitGrid and the previous one in NextGrid.
+	OnStep(c *Cella2d)
+	// OnRuleError is called when a rule fails while calculating a generation
+	OnRuleError(c *Cella2d, err error)
+	// OnStop is called when Run ends, after the last generation or an error
+	OnStop(c *Cella2d)
+}
+
+// ObserverFuncs is an observer made of functions, those not set are ignored
+type ObserverFuncs struct {
+	Step      func(c *Cella2d)
+	RuleError func(c *Cella2d, err error)
+	Stop      func(c *Cella2d)
+}
+
+// OnStep calls the Step function
+func (o *ObserverFuncs) OnStep(c *Cella2d) {
+	if o.Step != nil {
+		o.Step(c)
+	}
+}
+
+// OnRuleError calls the RuleError function
+func (o *ObserverFuncs) OnRuleError(c *Cella2d, err error) {
+	if o.RuleError != nil {
+		o.RuleError(c, err)
+	}
+}
+
+// OnStop calls the Stop function
+func (o *ObserverFuncs) OnStop(c *Cella2d) {
+	if o.Stop != nil {
+		o.Stop(c)
+	}
+}
+
+// AddObserver adds an observer of the automaton
+func (c *Cella2d) AddObserver(o Observer) {
+	c.observers = append(c.observers, o)
+}
+
+// RemoveObserver removes an observer of the automaton
+func (c *Cella2d) RemoveObserver(o Observer) {
+	for i, obs := range c.observers {
+		if obs == o {
+			c.observers = append(c.observers[:i], c.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetObservers gets the observers of the automaton
+func (c *Cella2d) GetObservers() []Observer {
+	return c.observers
+}
+
+// Step calculates the next generation, swaps the grids so the new
+// generation is in InitGrid and notifies the observers
+func (c *Cella2d) Step() error {
+	if err := c.NextGeneration(); err != nil {
+		for _, o := range c.observers {
+			o.OnRuleError(c, err)
+		}
+		return err
+	}
+	c.InitGrid, c.NextGrid = c.NextGrid, c.InitGrid
+	for _, o := range c.observers {
+		o.OnStep(c)
+	}
+	return nil
+}
+
+// Run calculates the given number of generations with Step and then
+// notifies the observers that the run stopped
+func (c *Cella2d) Run(generations int) error {
+	defer c.notifyStop()
+	for i := 0; i < generations; i++ {
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyStop notifies the observers that a run stopped
+func (c *Cella2d) notifyStop() {
+	for _, o := range c.observers {
+		o.OnStop(c)
+	}
+}
+
+// StreamMode is the content of the events of a Stream
+type StreamMode uint8
+
+const (
+	StreamSnapshots StreamMode = iota // A copy of the grid of each generation
+	StreamDeltas                      // The cells changed in each generation
+)
+
+// GenerationEvent is a generation sent by a Stream
+type GenerationEvent struct {
+	Generation int          // Generation of the automaton
+	Grid       *Grid        // Copy of the grid, only with StreamSnapshots
+	Changes    []CellChange // Cells changed from the previous generation, only with StreamDeltas
+	Err        error        // Error of a rule, the generation was not calculated
+}
+
+// Stream is an observer that sends the generations to a channel.
+// Sends block while the buffer of the channel is full, so the automaton
+// runs at the pace of the receiver. The channel is closed on OnStop.
+type Stream struct {
+	Mode   StreamMode
+	events chan GenerationEvent
+	closed bool
+}
+
+// NewStream creates a stream with a channel of the given buffer size
+func NewStream(mode StreamMode, buffer int) *Stream {
+	return &Stream{Mode: mode, events: make(chan GenerationEvent, buffer)}
+}
+
+// Events returns the channel of the generations
+func (s *Stream) Events() <-chan GenerationEvent {
+	return s.events
+}
+
+// OnStep sends the new generation
+func (s *Stream) OnStep(c *Cella2d) {
+	if s.closed {
+		return
+	}
+	ev := GenerationEvent{Generation: c.Generation}
+	if s.Mode == StreamDeltas {
+		ev.Changes = Diff(c.NextGrid, c.InitGrid)
+	} else {
+		ev.Grid = c.InitGrid.Clone()
+	}
+	s.events <- ev
+}
+
+// OnRuleError sends the error
+func (s *Stream) OnRuleError(c *Cella2d, err error) {
+	if s.closed {
+		return
+	}
+	s.events <- GenerationEvent{Generation: c.Generation, Err: err}
+}
+
+// OnStop closes the channel. Later generations are not sent.
+func (s *Stream) OnStop(c *Cella2d) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.events)
+}
@@ -0,0 +1,70 @@
+package cella
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	steps, stops := 0, 0
+	obs := &ObserverFuncs{
+		Step: func(c *Cella2d) {
+			steps++
+			if c.Generation != steps {
+				t.Fatalf("Step %d notified in generation %d", steps, c.Generation)
+			}
+		},
+		Stop: func(c *Cella2d) { stops++ },
+	}
+	ca.AddObserver(obs)
+	if err := ca.Run(3); err != nil {
+		t.Fatal(err)
+	}
+	if steps != 3 || stops != 1 {
+		t.Fatalf("Observer notified of %d steps and %d stops", steps, stops)
+	}
+	ca.RemoveObserver(obs)
+	if err := ca.Step(); err != nil || steps != 3 {
+		t.Fatal("Removed observer was notified")
+	}
+
+	var ruleErr error
+	ca.AddObserver(&ObserverFuncs{RuleError: func(c *Cella2d, err error) { ruleErr = err }})
+	ca.SetRules([]*Rule2d{NewRule2d("1+1", 1, 2)})
+	if err := ca.Run(1); err == nil || !errors.Is(ruleErr, err) {
+		t.Fatal("Rule error was not notified")
+	}
+}
+
+func TestStream(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	deltas := NewStream(StreamDeltas, 4)
+	snapshots := NewStream(StreamSnapshots, 4)
+	ca.AddObserver(deltas)
+	ca.AddObserver(snapshots)
+	if err := ca.Run(2); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ev := range deltas.Events() {
+		n++
+		// The blinker kills two cells and gives birth to two cells
+		if ev.Generation != n || len(ev.Changes) != 4 {
+			t.Fatalf("Generation %d has %d changes", ev.Generation, len(ev.Changes))
+		}
+	}
+	var last *Grid
+	for ev := range snapshots.Events() {
+		last = ev.Grid
+	}
+	if n != 2 || last == nil || !EqualsGrid(last, ca.InitGrid) || last == ca.InitGrid {
+		t.Fatal("Stream does not match the generations")
+	}
+}
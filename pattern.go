@@ -0,0 +1,677 @@
+package cella
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PatternFormat is a file format of a pattern
+type PatternFormat uint8
+
+const (
+	FormatUnknown   PatternFormat = iota // Format could not be detected
+	FormatRLE                            // Run Length Encoded (.rle)
+	FormatCells                          // Plaintext (.cells)
+	FormatLife105                        // Life 1.05 (.lif)
+	FormatLife106                        // Life 1.06 (.lif)
+	FormatMacrocell                      // Golly macrocell (.mc)
+)
+
+// String returns the name of the format
+func (f PatternFormat) String() string {
+	switch f {
+	case FormatRLE:
+		return "rle"
+	case FormatCells:
+		return "cells"
+	case FormatLife105:
+		return "life105"
+	case FormatLife106:
+		return "life106"
+	case FormatMacrocell:
+		return "mc"
+	}
+	return "unknown"
+}
+
+// ParsePatternFormat parses a format name or file extension, e.g. "rle" or ".mc"
+func ParsePatternFormat(name string) (PatternFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "rle":
+		return FormatRLE, nil
+	case "cells", "txt":
+		return FormatCells, nil
+	case "life105", "lif", "life":
+		return FormatLife105, nil
+	case "life106":
+		return FormatLife106, nil
+	case "mc", "macrocell":
+		return FormatMacrocell, nil
+	}
+	return FormatUnknown, fmt.Errorf("unknown pattern format %q", name)
+}
+
+// PatternInfo is the information read along with a pattern
+type PatternInfo struct {
+	Format PatternFormat // Format of the pattern
+	Rule   string        // Rule of the pattern, empty if not given
+}
+
+// maxPatternCells limits the number of cells read from coordinate based formats
+const maxPatternCells = 1 << 24
+
+// patternCell is a cell in a pattern with unbounded coordinates
+type patternCell struct {
+	x, y  int
+	state Cell
+}
+
+// gridFromCells creates the smallest grid containing all the cells
+func gridFromCells(cells []patternCell) *Grid {
+	if len(cells) == 0 {
+		return NewGrid(1, 1)
+	}
+	minX, minY, maxX, maxY := cells[0].x, cells[0].y, cells[0].x, cells[0].y
+	for _, c := range cells {
+		if c.x < minX {
+			minX = c.x
+		}
+		if c.x > maxX {
+			maxX = c.x
+		}
+		if c.y < minY {
+			minY = c.y
+		}
+		if c.y > maxY {
+			maxY = c.y
+		}
+	}
+	g := NewGrid(maxX-minX+1, maxY-minY+1)
+	for _, c := range cells {
+		g.SetCell(c.x-minX, c.y-minY, c.state)
+	}
+	return g
+}
+
+// checkPatternSize checks that the cells fit in a grid of a reasonable size
+func checkPatternSize(cells []patternCell) error {
+	if len(cells) == 0 {
+		return nil
+	}
+	minX, minY, maxX, maxY := cells[0].x, cells[0].y, cells[0].x, cells[0].y
+	for _, c := range cells {
+		if c.x < minX {
+			minX = c.x
+		}
+		if c.x > maxX {
+			maxX = c.x
+		}
+		if c.y < minY {
+			minY = c.y
+		}
+		if c.y > maxY {
+			maxY = c.y
+		}
+	}
+	w, h := maxX-minX+1, maxY-minY+1
+	if w <= 0 || h <= 0 || w > maxPatternCells/h {
+		return fmt.Errorf("pattern bounding box is too large")
+	}
+	return nil
+}
+
+// checkTwoStates checks that the grid only has states 0 and 1
+func checkTwoStates(g *Grid, format string) error {
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.GetCell(x, y) > 1 {
+				return fmt.Errorf("%s: state %d at (%d, %d) can not be written, only 2 states are supported",
+					format, g.GetCell(x, y), x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// DetectPatternFormat detects the format of a pattern from its content
+func DetectPatternFormat(data []byte) PatternFormat {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "[M2]"):
+			return FormatMacrocell
+		case strings.HasPrefix(line, "#Life 1.05"):
+			return FormatLife105
+		case strings.HasPrefix(line, "#Life 1.06"):
+			return FormatLife106
+		case strings.HasPrefix(line, "!"):
+			return FormatCells
+		case strings.HasPrefix(line, "#"):
+			continue
+		case rleHeaderField.MatchString(line) && strings.HasPrefix(strings.TrimLeft(line, " "), "x"):
+			return FormatRLE
+		case life106Line.MatchString(line):
+			return FormatLife106
+		case strings.Trim(line, ".O*") == "":
+			return FormatCells
+		}
+		return FormatUnknown
+	}
+	return FormatUnknown
+}
+
+// LoadPattern reads a pattern in any of the supported formats,
+// detecting the format from the content
+func LoadPattern(r io.Reader) (*Grid, *PatternInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &PatternInfo{Format: DetectPatternFormat(data)}
+	var g *Grid
+	switch info.Format {
+	case FormatRLE:
+		var h *RLEHeader
+		g, h, err = ReadRLE(bytes.NewReader(data))
+		if h != nil {
+			info.Rule = h.Rule
+		}
+	case FormatCells:
+		g, err = ReadCells(bytes.NewReader(data))
+	case FormatLife105:
+		g, info.Rule, err = ReadLife105(bytes.NewReader(data))
+	case FormatLife106:
+		g, err = ReadLife106(bytes.NewReader(data))
+	case FormatMacrocell:
+		g, info.Rule, err = ReadMacrocell(bytes.NewReader(data))
+	default:
+		return nil, nil, fmt.Errorf("pattern: unknown format")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, info, nil
+}
+
+// SavePattern writes a grid in the given format. The rule is written
+// in the formats that support it and ignored in the others.
+func SavePattern(w io.Writer, g *Grid, format PatternFormat, rule string) error {
+	switch format {
+	case FormatRLE:
+		return WriteRLE(w, g, rule)
+	case FormatCells:
+		return WriteCells(w, g)
+	case FormatLife105:
+		return WriteLife105(w, g, rule)
+	case FormatLife106:
+		return WriteLife106(w, g)
+	case FormatMacrocell:
+		return WriteMacrocell(w, g, rule)
+	}
+	return fmt.Errorf("pattern: unknown format")
+}
+
+// ReadCells reads a pattern in plaintext format.
+// Lines starting with '!' are comments, '.' is a dead cell and 'O' or '*'
+// an alive cell. Rows shorter than the widest row are padded with dead cells.
+func ReadCells(r io.Reader) (*Grid, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	rows := []string{}
+	width := 0
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		if strings.Trim(line, ".O*") != "" {
+			return nil, fmt.Errorf("cells: line %d: invalid row %q", len(rows)+1, line)
+		}
+		rows = append(rows, line)
+		if len(line) > width {
+			width = len(line)
+		}
+		if len(rows)*width > maxPatternCells {
+			return nil, fmt.Errorf("cells: pattern is too large")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cells: %w", err)
+	}
+	// Trailing empty lines are not part of the pattern
+	for len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows) == 0 || width == 0 {
+		return NewGrid(1, 1), nil
+	}
+	g := NewGrid(width, len(rows))
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			if row[x] != '.' {
+				g.SetCell(x, y, 1)
+			}
+		}
+	}
+	return g, nil
+}
+
+// WriteCells writes a two state grid in plaintext format
+func WriteCells(w io.Writer, g *Grid) error {
+	if err := checkTwoStates(g, "cells"); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	row := make([]byte, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			row[x] = '.'
+			if g.GetCell(x, y) != 0 {
+				row[x] = 'O'
+			}
+		}
+		bw.Write(bytes.TrimRight(row, "."))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// ReadLife105 reads a pattern in Life 1.05 format and returns its rule.
+// Blocks of cells start with "#P x y" and use '.' for dead and '*' for alive cells.
+// "#N" is read as the rule B3/S23 and "#R s/b" as the given rule.
+func ReadLife105(r io.Reader) (*Grid, string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	cells := []patternCell{}
+	rule := ""
+	x0, y, line := 0, 0, 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#P"):
+			if _, err := fmt.Sscanf(text[2:], "%d %d", &x0, &y); err != nil {
+				return nil, "", fmt.Errorf("life105: line %d: invalid block position %q", line, text)
+			}
+			continue
+		case strings.HasPrefix(text, "#N"):
+			rule = "B3/S23"
+			continue
+		case strings.HasPrefix(text, "#R"):
+			rule = strings.TrimSpace(text[2:])
+			continue
+		case strings.HasPrefix(text, "#"):
+			continue
+		}
+		if strings.Trim(text, ".*") != "" {
+			return nil, "", fmt.Errorf("life105: line %d: invalid row %q", line, text)
+		}
+		for i := 0; i < len(text); i++ {
+			if text[i] == '*' {
+				cells = append(cells, patternCell{x0 + i, y, 1})
+				if len(cells) > maxPatternCells {
+					return nil, "", fmt.Errorf("life105: pattern is too large")
+				}
+			}
+		}
+		y++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, "", fmt.Errorf("life105: %w", err)
+	}
+	if err := checkPatternSize(cells); err != nil {
+		return nil, "", fmt.Errorf("life105: %w", err)
+	}
+	if rule != "" {
+		if rs, err := ParseRulestring(rule); err == nil {
+			rule = rs.String()
+		}
+	}
+	return gridFromCells(cells), rule, nil
+}
+
+// WriteLife105 writes a two state grid in Life 1.05 format as a single block
+func WriteLife105(w io.Writer, g *Grid, rule string) error {
+	if err := checkTwoStates(g, "life105"); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#Life 1.05\n")
+	if rule != "" {
+		// Life 1.05 writes rules in S/B notation
+		if rs, err := ParseRulestring(rule); err == nil && rs.NumStates == 2 && rs.Neighbourhood == NeighbourhoodMoore {
+			var sb strings.Builder
+			for _, n := range rs.Survival {
+				sb.WriteString(strconv.Itoa(n))
+			}
+			sb.WriteString("/")
+			for _, n := range rs.Birth {
+				sb.WriteString(strconv.Itoa(n))
+			}
+			fmt.Fprintf(bw, "#R %s\n", sb.String())
+		}
+	}
+	fmt.Fprintf(bw, "#P %d %d\n", -g.Width/2, -g.Height/2)
+	row := make([]byte, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			row[x] = '.'
+			if g.GetCell(x, y) != 0 {
+				row[x] = '*'
+			}
+		}
+		line := bytes.TrimRight(row, ".")
+		if len(line) == 0 {
+			line = []byte{'.'}
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// life106Line matches a coordinate line of a Life 1.06 pattern
+var life106Line = regexp.MustCompile(`^-?\d+\s+-?\d+$`)
+
+// ReadLife106 reads a pattern in Life 1.06 format, a list of "x y"
+// coordinates of alive cells
+func ReadLife106(r io.Reader) (*Grid, error) {
+	sc := bufio.NewScanner(r)
+	cells := []patternCell{}
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if !life106Line.MatchString(text) {
+			return nil, fmt.Errorf("life106: line %d: invalid coordinates %q", line, text)
+		}
+		fields := strings.Fields(text)
+		x, errX := strconv.Atoi(fields[0])
+		y, errY := strconv.Atoi(fields[1])
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("life106: line %d: invalid coordinates %q", line, text)
+		}
+		cells = append(cells, patternCell{x, y, 1})
+		if len(cells) > maxPatternCells {
+			return nil, fmt.Errorf("life106: pattern is too large")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("life106: %w", err)
+	}
+	if err := checkPatternSize(cells); err != nil {
+		return nil, fmt.Errorf("life106: %w", err)
+	}
+	return gridFromCells(cells), nil
+}
+
+// WriteLife106 writes the alive cells of a two state grid in Life 1.06 format
+func WriteLife106(w io.Writer, g *Grid) error {
+	if err := checkTwoStates(g, "life106"); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#Life 1.06\n")
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.GetCell(x, y) != 0 {
+				fmt.Fprintf(bw, "%d %d\n", x, y)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// mcNode is a node of a macrocell quadtree
+type mcNode struct {
+	level    int     // Size of the node is 2^level
+	children [4]int  // Indices of the nw, ne, sw, se children, 0 is an empty node
+	states   [4]Cell // States of a level 1 node
+	leaf     [8]byte // Rows of a level 3 two state leaf, bit 7 is the leftmost cell
+	cells    int     // Number of cells of the node, up to maxPatternCells + 1
+}
+
+// maxMacrocellVisits limits the number of nodes visited reading a macrocell
+// pattern. Empty nodes are skipped, so every node visited has cells.
+const maxMacrocellVisits = 4 * maxPatternCells
+
+// ReadMacrocell reads a pattern in Golly macrocell format and returns its rule
+func ReadMacrocell(r io.Reader) (*Grid, string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	nodes := []mcNode{{}}
+	rule := ""
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "[M2]"):
+			continue
+		case strings.HasPrefix(text, "#R"):
+			rule = strings.TrimSpace(text[2:])
+			continue
+		case strings.HasPrefix(text, "#"):
+			continue
+		}
+		var n mcNode
+		if c := text[0]; c == '.' || c == '*' || c == '$' {
+			n.level = 3
+			x, y := 0, 0
+			for i := 0; i < len(text); i++ {
+				switch text[i] {
+				case '$':
+					y++
+					x = 0
+				case '.':
+					x++
+				case '*':
+					if x > 7 || y > 7 {
+						return nil, "", fmt.Errorf("mc: line %d: leaf larger than 8x8", line)
+					}
+					n.leaf[y] |= 0x80 >> x
+					n.cells++
+					x++
+				default:
+					return nil, "", fmt.Errorf("mc: line %d: invalid leaf %q", line, text)
+				}
+			}
+		} else {
+			fields := strings.Fields(text)
+			if len(fields) != 5 {
+				return nil, "", fmt.Errorf("mc: line %d: invalid node %q", line, text)
+			}
+			var vals [5]int
+			for i, f := range fields {
+				v, err := strconv.Atoi(f)
+				if err != nil || v < 0 {
+					return nil, "", fmt.Errorf("mc: line %d: invalid node %q", line, text)
+				}
+				vals[i] = v
+			}
+			n.level = vals[0]
+			if n.level < 1 || n.level > 62 {
+				return nil, "", fmt.Errorf("mc: line %d: invalid level %d", line, n.level)
+			}
+			for i := 0; i < 4; i++ {
+				if n.level == 1 {
+					if vals[i+1] > 255 {
+						return nil, "", fmt.Errorf("mc: line %d: invalid state %d", line, vals[i+1])
+					}
+					n.states[i] = Cell(vals[i+1])
+					if n.states[i] != 0 {
+						n.cells++
+					}
+					continue
+				}
+				child := vals[i+1]
+				if child >= len(nodes) || (child != 0 && nodes[child].level != n.level-1) {
+					return nil, "", fmt.Errorf("mc: line %d: invalid child %d", line, child)
+				}
+				n.children[i] = child
+				// Children come before their parents, so their cells are known
+				n.cells += nodes[child].cells
+			}
+			if n.cells > maxPatternCells {
+				n.cells = maxPatternCells + 1
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, "", fmt.Errorf("mc: %w", err)
+	}
+	if len(nodes) == 1 {
+		return NewGrid(1, 1), rule, nil
+	}
+
+	root := len(nodes) - 1
+	if nodes[root].cells > maxPatternCells {
+		return nil, "", fmt.Errorf("mc: pattern is too large")
+	}
+	cells := make([]patternCell, 0, nodes[root].cells)
+	visits := 0
+	var walk func(idx, x, y int) error
+	walk = func(idx, x, y int) error {
+		n := &nodes[idx]
+		// Empty nodes are skipped, the format can reference them any number
+		// of times
+		if n.cells == 0 {
+			return nil
+		}
+		visits++
+		if visits > maxMacrocellVisits {
+			return fmt.Errorf("mc: too many nodes")
+		}
+		switch {
+		case n.level == 1:
+			for i, s := range n.states {
+				if s != 0 {
+					cells = append(cells, patternCell{x + i%2, y + i/2, s})
+				}
+			}
+		case n.level == 3 && n.children == [4]int{}:
+			for ly, row := range n.leaf {
+				for lx := 0; lx < 8; lx++ {
+					if row&(0x80>>lx) != 0 {
+						cells = append(cells, patternCell{x + lx, y + ly, 1})
+					}
+				}
+			}
+		default:
+			half := 1 << (n.level - 1)
+			for i, child := range n.children {
+				if err := walk(child, x+(i%2)*half, y+(i/2)*half); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(root, 0, 0); err != nil {
+		return nil, "", err
+	}
+	if err := checkPatternSize(cells); err != nil {
+		return nil, "", fmt.Errorf("mc: %w", err)
+	}
+	return gridFromCells(cells), rule, nil
+}
+
+// WriteMacrocell writes a grid in Golly macrocell format.
+// Two state grids use 8x8 leaves and multi-state grids use level 1 nodes.
+func WriteMacrocell(w io.Writer, g *Grid, rule string) error {
+	multiState := checkTwoStates(g, "mc") != nil
+	level := 1
+	if !multiState {
+		level = 3
+	}
+	for 1<<level < g.Width || 1<<level < g.Height {
+		level++
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[M2] (cella)\n")
+	if rule != "" {
+		fmt.Fprintf(bw, "#R %s\n", rule)
+	}
+	cell := func(x, y int) Cell {
+		if x >= g.Width || y >= g.Height {
+			return 0
+		}
+		return g.GetCell(x, y)
+	}
+	// Nodes are deduplicated by their line
+	index := make(map[string]int)
+	var build func(x, y, level int) int
+	build = func(x, y, level int) int {
+		// Nodes outside of the grid are empty
+		if x >= g.Width || y >= g.Height {
+			return 0
+		}
+		var sb strings.Builder
+		switch {
+		case level == 1:
+			s := [4]Cell{cell(x, y), cell(x+1, y), cell(x, y+1), cell(x+1, y+1)}
+			if s == [4]Cell{} {
+				return 0
+			}
+			fmt.Fprintf(&sb, "1 %d %d %d %d", s[0], s[1], s[2], s[3])
+		case level == 3 && !multiState:
+			empty := true
+			rows := make([]string, 8)
+			for ly := 0; ly < 8; ly++ {
+				row := make([]byte, 8)
+				for lx := 0; lx < 8; lx++ {
+					row[lx] = '.'
+					if cell(x+lx, y+ly) != 0 {
+						row[lx] = '*'
+						empty = false
+					}
+				}
+				rows[ly] = string(bytes.TrimRight(row, "."))
+			}
+			if empty {
+				return 0
+			}
+			sb.WriteString(strings.TrimRight(strings.Join(rows, "$"), "$"))
+			sb.WriteString("$")
+		default:
+			half := 1 << (level - 1)
+			nw := build(x, y, level-1)
+			ne := build(x+half, y, level-1)
+			sw := build(x, y+half, level-1)
+			se := build(x+half, y+half, level-1)
+			if nw == 0 && ne == 0 && sw == 0 && se == 0 {
+				return 0
+			}
+			fmt.Fprintf(&sb, "%d %d %d %d %d", level, nw, ne, sw, se)
+		}
+		key := sb.String()
+		if idx, ok := index[key]; ok {
+			return idx
+		}
+		index[key] = len(index) + 1
+		bw.WriteString(key)
+		bw.WriteByte('\n')
+		return len(index)
+	}
+	if build(0, 0, level) == 0 {
+		// Empty patterns are written as a single empty node
+		fmt.Fprintf(bw, "%d 0 0 0 0\n", level)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,120 @@
+package cella
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newGlider creates a grid with a glider
+func newGlider() *Grid {
+	g := NewGrid(3, 3)
+	g.SetCell(1, 0, 1)
+	g.SetCell(2, 1, 1)
+	g.SetCell(0, 2, 1)
+	g.SetCell(1, 2, 1)
+	g.SetCell(2, 2, 1)
+	return g
+}
+
+func TestLoadPatternFormats(t *testing.T) {
+	inputs := map[PatternFormat]string{
+		FormatRLE:       "#C glider\nx = 3, y = 3\nbo$2bo$3o!",
+		FormatCells:     "!Name: Glider\n.O\n..O\nOOO\n",
+		FormatLife105:   "#Life 1.05\n#N\n#P -1 -1\n.*\n..*\n***\n",
+		FormatLife106:   "#Life 1.06\n0 -1\n1 0\n-1 1\n0 1\n1 1\n",
+		FormatMacrocell: "[M2] (golly 3.0)\n#R B3/S23\n.*$..*$***$\n4 1 0 0 0\n",
+	}
+	for format, input := range inputs {
+		g, info, err := LoadPattern(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Format %s: %v", format, err)
+		}
+		if info.Format != format {
+			t.Fatalf("Format %s detected as %s", format, info.Format)
+		}
+		if !EqualsGrid(g, newGlider()) {
+			t.Fatalf("Format %s glider does not match", format)
+		}
+	}
+}
+
+func TestSavePatternRoundTrip(t *testing.T) {
+	g := NewGrid(20, 11)
+	g.SetCell(0, 0, 1)
+	g.SetCell(19, 10, 1)
+	g.SetCell(8, 3, 1)
+	for _, format := range []PatternFormat{FormatRLE, FormatCells, FormatLife105, FormatLife106, FormatMacrocell} {
+		var buf bytes.Buffer
+		if err := SavePattern(&buf, g, format, "B3/S23"); err != nil {
+			t.Fatalf("Format %s: %v", format, err)
+		}
+		read, info, err := LoadPattern(&buf)
+		if err != nil {
+			t.Fatalf("Format %s: %v", format, err)
+		}
+		if info.Format != format || !EqualsGrid(g, read) {
+			t.Fatalf("Format %s round trip does not match", format)
+		}
+	}
+}
+
+func TestMacrocellThinGrid(t *testing.T) {
+	g := NewGrid(70000, 1)
+	g.SetCell(0, 0, 1)
+	g.SetCell(69999, 0, 1)
+	var buf bytes.Buffer
+	if err := WriteMacrocell(&buf, g, ""); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ReadMacrocell(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualsGrid(g, read) {
+		t.Fatal("Thin macrocell round trip does not match")
+	}
+}
+
+func TestMacrocellSharedNodes(t *testing.T) {
+	// Every node references the previous one four times
+	nodes := func(first string) string {
+		var b strings.Builder
+		b.WriteString("[M2]\n" + first + "\n")
+		for l := 2; l <= 20; l++ {
+			fmt.Fprintf(&b, "%d %d %d %d %d\n", l, l-1, l-1, l-1, l-1)
+		}
+		return b.String()
+	}
+	g, _, err := ReadMacrocell(strings.NewReader(nodes("1 0 0 0 0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualsGrid(g, NewGrid(1, 1)) {
+		t.Fatal("Empty shared nodes must give an empty grid")
+	}
+	if _, _, err := ReadMacrocell(strings.NewReader(nodes("1 1 0 0 0"))); err == nil {
+		t.Fatal("Shared nodes with 4^19 cells must fail")
+	}
+}
+
+func TestMacrocellMultiState(t *testing.T) {
+	g := NewGrid(5, 3)
+	g.SetCell(0, 0, 3)
+	g.SetCell(4, 2, 2)
+	var buf bytes.Buffer
+	if err := WriteMacrocell(&buf, g, ""); err != nil {
+		t.Fatal(err)
+	}
+	read, _, err := ReadMacrocell(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualsGrid(g, read) {
+		t.Fatal("Multi-state macrocell round trip does not match")
+	}
+	if err := WriteCells(&buf, g); err == nil {
+		t.Fatal("Multi-state grid should not be written as plaintext")
+	}
+}
@@ -0,0 +1,184 @@
+package cella
+
+import (
+	"fmt"
+)
+
+// PatternKind is the kind of pattern found by the period analysis
+type PatternKind uint8
+
+const (
+	KindUnknown    PatternKind = iota // No recurrence was found
+	KindEmpty                         // All the cells died
+	KindStillLife                     // The grid does not change
+	KindOscillator                    // The grid recurs in the same place
+	KindSpaceship                     // The grid recurs translated
+)
+
+// String returns the name of the kind of pattern
+func (k PatternKind) String() string {
+	switch k {
+	case KindEmpty:
+		return "empty"
+	case KindStillLife:
+		return "still life"
+	case KindOscillator:
+		return "oscillator"
+	case KindSpaceship:
+		return "spaceship"
+	}
+	return "unknown"
+}
+
+// AnalyzeOptions are the options of the period analysis
+type AnalyzeOptions struct {
+	MaxGenerations int // Maximum number of generations run, 1000 if not set
+	MaxHistory     int // Maximum number of grids kept in memory, 65536 if not set
+}
+
+// PeriodResult is the result of the period analysis
+type PeriodResult struct {
+	Kind        PatternKind // Kind of pattern
+	Transient   int         // Generations before the cycle starts
+	Period      int         // Generations of the cycle, 0 if not found
+	DX, DY      int         // Displacement of a spaceship per period
+	Generations int         // Generations run by the analysis
+}
+
+// String returns a description of the result
+func (r *PeriodResult) String() string {
+	switch r.Kind {
+	case KindUnknown:
+		return fmt.Sprintf("no period found in %d generations", r.Generations)
+	case KindSpaceship:
+		return fmt.Sprintf("spaceship with period %d moving (%d, %d) after %d generations", r.Period, r.DX, r.DY, r.Transient)
+	}
+	return fmt.Sprintf("%s with period %d after %d generations", r.Kind, r.Period, r.Transient)
+}
+
+// periodEntry is a generation stored in the history of the analysis
+type periodEntry struct {
+	generation int
+	x, y       int   // Position of the bounding box
+	grid       *Grid // Cells inside the bounding box
+}
+
+// Analyze runs the automaton from its current state until a grid recurs,
+// up to translation, and reports the transient, the period and the kind of
+// pattern. Grids are looked up by a hash of the cells inside their bounding
+// box and confirmed comparing those cells, so hash collisions are not taken
+// as recurrences. The history is limited by MaxHistory: when it is full
+// the history is cleared and the transient is found running a copy of the
+// initial state, so periods up to MaxHistory are always detected.
+// The automaton is left on the last generation run.
+func Analyze(c *Cella2d, opts AnalyzeOptions) (*PeriodResult, error) {
+	if opts.MaxGenerations <= 0 {
+		opts.MaxGenerations = 1000
+	}
+	if opts.MaxHistory <= 0 {
+		opts.MaxHistory = 1 << 16
+	}
+	start := c.Clone()
+	history := make(map[uint64][]periodEntry)
+	kept := 0
+	cleared := false
+	res := new(PeriodResult)
+	for gen := 0; ; gen++ {
+		g, h, x, y := content(c.InitGrid)
+		if prev, ok := findEntry(history[h], g); ok {
+			res.Period = gen - prev.generation
+			res.DX, res.DY = x-prev.x, y-prev.y
+			res.Transient = prev.generation
+			res.Generations = gen
+			break
+		}
+		if gen == opts.MaxGenerations {
+			res.Generations = gen
+			return res, nil
+		}
+		if kept >= opts.MaxHistory {
+			history = make(map[uint64][]periodEntry)
+			kept = 0
+			cleared = true
+		}
+		history[h] = append(history[h], periodEntry{gen, x, y, g})
+		kept++
+		if err := c.Step(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cleared {
+		transient, err := findTransient(start, res.Period, res.DX, res.DY, res.Generations)
+		if err != nil {
+			return nil, err
+		}
+		res.Transient = transient
+	}
+	switch g, _, _, _ := content(c.InitGrid); {
+	case g == nil:
+		res.Kind = KindEmpty
+	case res.DX != 0 || res.DY != 0:
+		res.Kind = KindSpaceship
+	case res.Period == 1:
+		res.Kind = KindStillLife
+	default:
+		res.Kind = KindOscillator
+	}
+	return res, nil
+}
+
+// findTransient runs two copies of the automaton, one of them period
+// generations ahead, until both grids match with the given displacement
+func findTransient(c *Cella2d, period, dx, dy, maxTransient int) (int, error) {
+	ahead := c.Clone()
+	for i := 0; i < period; i++ {
+		if err := ahead.Step(); err != nil {
+			return 0, err
+		}
+	}
+	for transient := 0; transient <= maxTransient; transient++ {
+		g1, h1, x1, y1 := content(c.InitGrid)
+		g2, h2, x2, y2 := content(ahead.InitGrid)
+		if h1 == h2 && x2-x1 == dx && y2-y1 == dy && sameContent(g1, g2) {
+			return transient, nil
+		}
+		for _, a := range []*Cella2d{c, ahead} {
+			if err := a.Step(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return 0, fmt.Errorf("period: cycle of period %d not found again", period)
+}
+
+// content returns the cells inside the bounding box of the cells not in
+// state 0, their hash and the position of the bounding box.
+// The grid is nil if all the cells are in state 0.
+func content(g *Grid) (*Grid, uint64, int, int) {
+	x, y, w, h, ok := g.BoundingBox()
+	if !ok {
+		return nil, 0, 0, 0
+	}
+	t := g.SubGrid(x, y, w, h)
+	return t, gridHash(t), x, y
+}
+
+// sameContent reports if the cells returned by content are the same
+func sameContent(a, b *Grid) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return EqualsGrid(a, b)
+}
+
+// findEntry returns the entry of the history with the same content as the
+// grid
+func findEntry(entries []periodEntry, g *Grid) (periodEntry, bool) {
+	for _, e := range entries {
+		if sameContent(e.grid, g) {
+			return e, true
+		}
+	}
+	return periodEntry{}, false
+}
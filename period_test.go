@@ -0,0 +1,95 @@
+package cella
+
+import (
+	"testing"
+)
+
+func TestAnalyzePatterns(t *testing.T) {
+	tests := []struct {
+		name      string
+		cells     [][2]int
+		kind      PatternKind
+		period    int
+		transient int
+		dx, dy    int
+	}{
+		{"block", [][2]int{{3, 3}, {4, 3}, {3, 4}, {4, 4}}, KindStillLife, 1, 0, 0, 0},
+		{"blinker", [][2]int{{3, 4}, {4, 4}, {5, 4}}, KindOscillator, 2, 0, 0, 0},
+		{"glider", [][2]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}, KindSpaceship, 4, 0, 1, 1},
+		{"domino", [][2]int{{3, 3}, {4, 3}}, KindEmpty, 1, 1, 0, 0},
+		// Pre-block: three cells of a block become a block
+		{"pre-block", [][2]int{{3, 3}, {4, 3}, {3, 4}}, KindStillLife, 1, 1, 0, 0},
+	}
+	for _, test := range tests {
+		for _, maxHistory := range []int{0, 4} {
+			ca := newGameOfLife(12, 12)
+			for _, c := range test.cells {
+				ca.InitGrid.SetCell(c[0], c[1], 1)
+			}
+			res, err := Analyze(ca, AnalyzeOptions{MaxGenerations: 100, MaxHistory: maxHistory})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Kind != test.kind || res.Period != test.period || res.Transient != test.transient ||
+				res.DX != test.dx || res.DY != test.dy {
+				t.Fatalf("Analysis of %s with history %d does not match: %s", test.name, maxHistory, res)
+			}
+		}
+	}
+}
+
+func TestAnalyzeHashCollision(t *testing.T) {
+	// All the phases of a glider collide with a hash of the population
+	defer func(h func(*Grid) uint64) { gridHash = h }(gridHash)
+	gridHash = func(g *Grid) uint64 {
+		n := uint64(0)
+		for _, row := range g.Cells {
+			for _, s := range row {
+				n += uint64(s)
+			}
+		}
+		return n
+	}
+	ca := newGameOfLife(12, 12)
+	for _, c := range [][2]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}} {
+		ca.InitGrid.SetCell(c[0], c[1], 1)
+	}
+	res, err := Analyze(ca, AnalyzeOptions{MaxGenerations: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Kind != KindSpaceship || res.Period != 4 || res.DX != 1 || res.DY != 1 {
+		t.Fatalf("Analysis with colliding hashes does not match: %s", res)
+	}
+}
+
+func TestAnalyzeClearedHistory(t *testing.T) {
+	// Pre-block with a history of one generation
+	ca := newGameOfLife(12, 12)
+	ca.InitGrid.SetCell(3, 3, 1)
+	ca.InitGrid.SetCell(4, 3, 1)
+	ca.InitGrid.SetCell(3, 4, 1)
+	res, err := Analyze(ca, AnalyzeOptions{MaxGenerations: 100, MaxHistory: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Kind != KindStillLife || res.Transient != 1 || res.Period != 1 {
+		t.Fatalf("Analysis with cleared history does not match: %s", res)
+	}
+}
+
+func TestAnalyzeNotFound(t *testing.T) {
+	ca := newGameOfLife(12, 12)
+	ca.InitGrid.SetCell(1, 0, 1)
+	ca.InitGrid.SetCell(2, 1, 1)
+	ca.InitGrid.SetCell(0, 2, 1)
+	ca.InitGrid.SetCell(1, 2, 1)
+	ca.InitGrid.SetCell(2, 2, 1)
+	res, err := Analyze(ca, AnalyzeOptions{MaxGenerations: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Kind != KindUnknown || res.Period != 0 || ca.Generation != 3 {
+		t.Fatalf("Analysis should not find a period: %s", res)
+	}
+}
@@ -0,0 +1,164 @@
+package cella
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// PopulationRecord is the population of a generation
+type PopulationRecord struct {
+	Generation    int   `json:"generation"`
+	CellsPerState []int `json:"cellsPerState"`
+	Population    int   `json:"population"` // Cells not in state 0
+	Births        int   `json:"births"`     // Cells that went from state 0 to another state
+	Deaths        int   `json:"deaths"`     // Cells that went to state 0 from another state
+	NetChange     int   `json:"netChange"`  // Change of the population from the previous generation
+}
+
+// PopulationRecorder is an observer that records the population of each
+// generation. Births and deaths are taken from the transitions of the
+// automaton, they are 0 in the first record.
+type PopulationRecorder struct {
+	Records []PopulationRecord
+}
+
+// RecordPopulation creates a recorder with the current generation and adds
+// it as an observer of the automaton
+func (c *Cella2d) RecordPopulation() *PopulationRecorder {
+	c.CountCellsPerState()
+	r := new(PopulationRecorder)
+	r.add(c, false)
+	c.AddObserver(r)
+	return r
+}
+
+// add records the current generation of the automaton
+func (r *PopulationRecorder) add(c *Cella2d, transitions bool) {
+	rec := PopulationRecord{
+		Generation:    c.Generation,
+		CellsPerState: append([]int(nil), c.CellsPerState...),
+		Population:    c.Width*c.Height - c.CellsPerState[0],
+	}
+	if transitions {
+		for s := 1; s < len(c.Transitions); s++ {
+			rec.Births += c.Transitions[0][s]
+			rec.Deaths += c.Transitions[s][0]
+		}
+	}
+	if n := len(r.Records); n > 0 {
+		rec.NetChange = rec.Population - r.Records[n-1].Population
+	}
+	r.Records = append(r.Records, rec)
+}
+
+// OnStep records the new generation
+func (r *PopulationRecorder) OnStep(c *Cella2d) {
+	r.add(c, true)
+}
+
+// OnRuleError does nothing, the generation was not calculated
+func (r *PopulationRecorder) OnRuleError(c *Cella2d, err error) {}
+
+// OnStop does nothing, the recorder keeps recording if the automaton runs again
+func (r *PopulationRecorder) OnStop(c *Cella2d) {}
+
+// Population returns the population of each record
+func (r *PopulationRecorder) Population() []int {
+	p := make([]int, len(r.Records))
+	for i, rec := range r.Records {
+		p[i] = rec.Population
+	}
+	return p
+}
+
+// PopulationSummary are the statistics of the population of the records
+type PopulationSummary struct {
+	Generations   int     `json:"generations"` // Number of records
+	Min           int     `json:"min"`
+	MinGeneration int     `json:"minGeneration"` // First generation with the minimum population
+	Max           int     `json:"max"`
+	MaxGeneration int     `json:"maxGeneration"` // First generation with the maximum population
+	Mean          float64 `json:"mean"`
+	StableSince   int     `json:"stableSince"` // Generation since which the population did not change, -1 if it changed in the last one
+	TotalBirths   int     `json:"totalBirths"`
+	TotalDeaths   int     `json:"totalDeaths"`
+}
+
+// Summary returns the statistics of the population
+func (r *PopulationRecorder) Summary() PopulationSummary {
+	s := PopulationSummary{Generations: len(r.Records), StableSince: -1}
+	if len(r.Records) == 0 {
+		return s
+	}
+	first := r.Records[0]
+	s.Min, s.Max = first.Population, first.Population
+	s.MinGeneration, s.MaxGeneration = first.Generation, first.Generation
+	sum := 0
+	for _, rec := range r.Records {
+		if rec.Population < s.Min {
+			s.Min, s.MinGeneration = rec.Population, rec.Generation
+		}
+		if rec.Population > s.Max {
+			s.Max, s.MaxGeneration = rec.Population, rec.Generation
+		}
+		sum += rec.Population
+		s.TotalBirths += rec.Births
+		s.TotalDeaths += rec.Deaths
+	}
+	s.Mean = float64(sum) / float64(len(r.Records))
+
+	last := len(r.Records) - 1
+	if last == 0 || r.Records[last].NetChange != 0 {
+		return s
+	}
+	i := last
+	for i > 0 && r.Records[i].NetChange == 0 {
+		i--
+	}
+	s.StableSince = r.Records[i].Generation
+	return s
+}
+
+// WriteCSV writes the records as CSV with a header, one generation per row:
+// generation, population, births, deaths, net change and the cells of each state
+func (r *PopulationRecorder) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	numStates := 0
+	if len(r.Records) > 0 {
+		numStates = len(r.Records[0].CellsPerState)
+	}
+	header := []string{"generation", "population", "births", "deaths", "net"}
+	for s := 0; s < numStates; s++ {
+		header = append(header, "state"+strconv.Itoa(s))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, len(header))
+	for _, rec := range r.Records {
+		row = row[:0]
+		for _, v := range []int{rec.Generation, rec.Population, rec.Births, rec.Deaths, rec.NetChange} {
+			row = append(row, strconv.Itoa(v))
+		}
+		for _, v := range rec.CellsPerState {
+			row = append(row, strconv.Itoa(v))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the summary and the records as JSON
+func (r *PopulationRecorder) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Summary PopulationSummary  `json:"summary"`
+		Records []PopulationRecord `json:"records"`
+	}{r.Summary(), r.Records})
+}
@@ -0,0 +1,48 @@
+package cella
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPopulationRecorder(t *testing.T) {
+	// Pre-block: a cell is born and the block is stable
+	ca := newGameOfLife(6, 6)
+	ca.InitGrid.SetCell(2, 2, 1)
+	ca.InitGrid.SetCell(3, 2, 1)
+	ca.InitGrid.SetCell(2, 3, 1)
+	rec := ca.RecordPopulation()
+	if err := ca.Run(3); err != nil {
+		t.Fatal(err)
+	}
+	pop := rec.Population()
+	if len(pop) != 4 || pop[0] != 3 || pop[1] != 4 || rec.Records[1].Births != 1 || rec.Records[1].NetChange != 1 {
+		t.Fatalf("Records do not match: %+v", rec.Records)
+	}
+	s := rec.Summary()
+	if s.Min != 3 || s.Max != 4 || s.MaxGeneration != 1 || s.Mean != 3.75 || s.StableSince != 1 || s.TotalBirths != 1 {
+		t.Fatalf("Summary does not match: %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := rec.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[0] != "generation,population,births,deaths,net,state0,state1" || lines[2] != "1,4,1,0,1,32,4" {
+		t.Fatalf("CSV does not match:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := rec.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Summary PopulationSummary
+		Records []PopulationRecord
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil || out.Summary != s || len(out.Records) != 4 {
+		t.Fatalf("JSON does not match: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package cella
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Palette is the color of each state, indexed by the state
+type Palette []color.Color
+
+// DefaultPalette creates a palette where state 0 is black, state 1 is white
+// and the rest of the states are spread over the hue circle
+func DefaultPalette(numStates int) Palette {
+	p := make(Palette, numStates)
+	for s := range p {
+		switch s {
+		case 0:
+			p[s] = color.RGBA{0, 0, 0, 0xff}
+		case 1:
+			p[s] = color.RGBA{0xff, 0xff, 0xff, 0xff}
+		default:
+			p[s] = hueColor(float64(s-2) / float64(numStates-2))
+		}
+	}
+	return p
+}
+
+// hueColor returns a saturated color with the hue given in [0, 1)
+func hueColor(h float64) color.RGBA {
+	h = h * 6
+	x := uint8(255 * (1 - math.Abs(math.Mod(h, 2)-1)))
+	switch int(h) % 6 {
+	case 0:
+		return color.RGBA{0xff, x, 0, 0xff}
+	case 1:
+		return color.RGBA{x, 0xff, 0, 0xff}
+	case 2:
+		return color.RGBA{0, 0xff, x, 0xff}
+	case 3:
+		return color.RGBA{0, x, 0xff, 0xff}
+	case 4:
+		return color.RGBA{x, 0, 0xff, 0xff}
+	}
+	return color.RGBA{0xff, 0, x, 0xff}
+}
+
+// Palette returns the colors of the states given in the @COLORS section.
+// States without color use the default palette.
+func (g *GollyRule) Palette() Palette {
+	p := DefaultPalette(g.NumStates)
+	for s, c := range g.Colors {
+		p[s] = c
+	}
+	return p
+}
+
+// RenderOptions are the options to render a grid as an image
+type RenderOptions struct {
+	Palette      Palette     // Colors of the states, the default palette is used if nil
+	CellSize     int         // Size of each cell in pixels, 1 if not set
+	GridLines    bool        // Draw lines of 1 pixel between cells
+	GridColor    color.Color // Color of the grid lines, gray if not set
+	InvalidColor color.Color // Color of the cells in states out of range, magenta if not set
+}
+
+// palette returns the color palette of the image: the colors of the states
+// followed by the color of the grid lines and, if invalid is set, the color
+// of the states out of range. Cells cannot be out of range with 256 states,
+// so that color is only added with less states.
+func (o *RenderOptions) palette(numStates int, invalid bool) (color.Palette, error) {
+	invalid = invalid && numStates < 256
+	size := numStates
+	if o.GridLines {
+		size++
+	}
+	if invalid {
+		size++
+	}
+	if size > 256 {
+		return nil, fmt.Errorf("render: %d states need %d colors, more than 256", numStates, size)
+	}
+	p := o.Palette
+	if len(p) < numStates {
+		def := DefaultPalette(numStates)
+		copy(def, p)
+		p = def
+	}
+	cp := make(color.Palette, 0, size)
+	cp = append(cp, p[:numStates]...)
+	if o.GridLines {
+		gc := o.GridColor
+		if gc == nil {
+			gc = color.RGBA{0x40, 0x40, 0x40, 0xff}
+		}
+		cp = append(cp, gc)
+	}
+	if invalid {
+		ic := o.InvalidColor
+		if ic == nil {
+			ic = color.RGBA{0xff, 0, 0xff, 0xff}
+		}
+		cp = append(cp, ic)
+	}
+	return cp, nil
+}
+
+// maxState returns the greatest state in the grid
+func maxState(g *Grid) Cell {
+	m := Cell(0)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if s := g.GetCell(x, y); s > m {
+				m = s
+			}
+		}
+	}
+	return m
+}
+
+// RenderGrid renders the grid as a paletted image
+func RenderGrid(g *Grid, opts RenderOptions) (*image.Paletted, error) {
+	numStates := int(maxState(g)) + 1
+	cp, err := opts.palette(numStates, false)
+	if err != nil {
+		return nil, err
+	}
+	return renderGrid(g, &opts, cp, numStates), nil
+}
+
+// renderGrid renders the grid using the color palette created by palette
+// for the number of states. Cells out of range take the last color.
+func renderGrid(g *Grid, opts *RenderOptions, cp color.Palette, numStates int) *image.Paletted {
+	size := opts.CellSize
+	if size <= 0 {
+		size = 1
+	}
+	step, offset := size, 0
+	if opts.GridLines {
+		step, offset = size+1, 1
+	}
+	img := image.NewPaletted(image.Rect(0, 0, g.Width*step+offset, g.Height*step+offset), cp)
+	if opts.GridLines {
+		line := uint8(numStates)
+		for i := range img.Pix {
+			img.Pix[i] = line
+		}
+	}
+	invalid := uint8(len(cp) - 1)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			idx := uint8(g.GetCell(x, y))
+			if int(idx) >= numStates {
+				idx = invalid
+			}
+			for py := 0; py < size; py++ {
+				row := (y*step+offset+py)*img.Stride + x*step + offset
+				for px := 0; px < size; px++ {
+					img.Pix[row+px] = idx
+				}
+			}
+		}
+	}
+	return img
+}
+
+// EncodePNG renders the grid and writes it as a PNG image
+func EncodePNG(w io.Writer, g *Grid, opts RenderOptions) error {
+	img, err := RenderGrid(g, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// GIFOptions are the options of an animated GIF
+type GIFOptions struct {
+	Delay     int // Delay between frames in 100ths of a second
+	LoopCount int // 0 loops forever, -1 plays once, n plays n+1 times
+}
+
+// EncodeGIF renders the grids as the frames of an animated GIF.
+// All the frames share the same palette.
+func EncodeGIF(w io.Writer, frames []*Grid, opts RenderOptions, gifOpts GIFOptions) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("render: no frames to encode")
+	}
+	numStates := 0
+	for _, f := range frames {
+		if n := int(maxState(f)) + 1; n > numStates {
+			numStates = n
+		}
+	}
+	cp, err := opts.palette(numStates, false)
+	if err != nil {
+		return err
+	}
+	anim := &gif.GIF{LoopCount: gifOpts.LoopCount}
+	for _, f := range frames {
+		anim.Image = append(anim.Image, renderGrid(f, &opts, cp, numStates))
+		anim.Delay = append(anim.Delay, gifOpts.Delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// EncodeGenerationsGIF runs the automaton for the given number of generations
+// and writes each generation, starting with the current one, as a frame
+func EncodeGenerationsGIF(w io.Writer, c *Cella2d, generations int, opts RenderOptions, gifOpts GIFOptions) error {
+	if opts.Palette == nil {
+		opts.Palette = DefaultPalette(c.NumStates)
+	}
+	cp, err := opts.palette(c.NumStates, true)
+	if err != nil {
+		return err
+	}
+	anim := &gif.GIF{LoopCount: gifOpts.LoopCount}
+	for i := 0; ; i++ {
+		anim.Image = append(anim.Image, renderGrid(c.InitGrid, &opts, cp, c.NumStates))
+		anim.Delay = append(anim.Delay, gifOpts.Delay)
+		if i == generations {
+			break
+		}
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,80 @@
+package cella
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestRenderGrid(t *testing.T) {
+	g := NewGrid(2, 2)
+	g.SetCell(1, 0, 1)
+	g.SetCell(0, 1, 2)
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	img, err := RenderGrid(g, RenderOptions{CellSize: 3, GridLines: true, GridColor: red})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 9 || img.Bounds().Dy() != 9 {
+		t.Fatalf("Image size is %v", img.Bounds())
+	}
+	checks := []struct {
+		x, y int
+		c    color.Color
+	}{
+		{0, 0, red},
+		{1, 1, color.RGBA{0, 0, 0, 0xff}},
+		{5, 3, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{4, 4, red},
+		{3, 7, DefaultPalette(3)[2]},
+	}
+	for _, check := range checks {
+		if img.At(check.x, check.y) != check.c {
+			t.Fatalf("Pixel (%d, %d) is %v, expected %v", check.x, check.y, img.At(check.x, check.y), check.c)
+		}
+	}
+}
+
+func TestRenderGridColors(t *testing.T) {
+	g := NewGrid(2, 1)
+	g.SetCell(1, 0, 255)
+	if _, err := RenderGrid(g, RenderOptions{GridLines: true}); err == nil {
+		t.Fatal("256 states with grid lines should send an error")
+	}
+	if _, err := RenderGrid(g, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// States out of range do not take the color of the grid lines
+	g.SetCell(1, 0, 5)
+	red, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+	opts := RenderOptions{GridLines: true, GridColor: red, InvalidColor: blue}
+	cp, err := opts.palette(2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := renderGrid(g, &opts, cp, 2)
+	if img.At(0, 0) != red || img.At(3, 1) != blue || img.At(1, 1) != DefaultPalette(2)[0] {
+		t.Fatalf("Colors are %v, %v and %v", img.At(0, 0), img.At(3, 1), img.At(1, 1))
+	}
+}
+
+func TestEncodeGenerationsGIF(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	ca.InitGrid.SetCell(1, 2, 1)
+	ca.InitGrid.SetCell(2, 2, 1)
+	ca.InitGrid.SetCell(3, 2, 1)
+	var buf bytes.Buffer
+	err := EncodeGenerationsGIF(&buf, ca, 4, RenderOptions{CellSize: 2}, GIFOptions{Delay: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 5 || anim.Delay[0] != 10 || ca.Generation != 4 {
+		t.Fatalf("GIF has %d frames, automaton is on generation %d", len(anim.Image), ca.Generation)
+	}
+}
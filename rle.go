@@ -0,0 +1,286 @@
+package cella
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RLEHeader is the header line of a Run Length Encoded pattern:
+// "x = <width>, y = <height>, rule = <rule>"
+type RLEHeader struct {
+	Width  int    // Width of the pattern
+	Height int    // Height of the pattern
+	Rule   string // Rule of the pattern, empty if not given
+}
+
+// rleHeaderField matches each "key = value" pair of the header line
+var rleHeaderField = regexp.MustCompile(`(\w+)\s*=\s*([^,]*)`)
+
+// maxRLECells limits the size of a grid read from an RLE pattern
+const maxRLECells = 1 << 28
+
+// ReadRLE reads a pattern in RLE format into a new grid.
+// Two state patterns use 'b' (dead) and 'o' (alive), multi-state patterns
+// use '.' for state 0 and 'A'-'X' for states 1-24, with the prefixes 'p'-'y'
+// for the following states. Comment lines starting with '#' are ignored.
+func ReadRLE(r io.Reader) (*Grid, *RLEHeader, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<24)
+	var header *RLEHeader
+	var body strings.Builder
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if header == nil {
+			h, err := parseRLEHeader(text)
+			if err != nil {
+				return nil, nil, fmt.Errorf("rle: line %d: %w", line, err)
+			}
+			header = h
+			continue
+		}
+		body.WriteString(text)
+		if strings.Contains(text, "!") {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("rle: %w", err)
+	}
+	if header == nil {
+		return nil, nil, fmt.Errorf("rle: missing header line")
+	}
+	if header.Width > maxRLECells/header.Height {
+		return nil, nil, fmt.Errorf("rle: pattern %dx%d is too large", header.Width, header.Height)
+	}
+
+	g := NewGrid(header.Width, header.Height)
+	x, y, count := 0, 0, 0
+	prefix := byte(0)
+	data := body.String()
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		if ch >= '0' && ch <= '9' {
+			count = count*10 + int(ch-'0')
+			if count > maxRLECells {
+				return nil, nil, fmt.Errorf("rle: run length too large at offset %d", i)
+			}
+			continue
+		}
+		n := count
+		if n == 0 {
+			n = 1
+		}
+		count = 0
+		// A state prefix is always followed by the character of the cell
+		if (ch == '!' || ch == '$') && prefix != 0 {
+			return nil, nil, fmt.Errorf("rle: invalid state prefix %q before %q at offset %d", prefix, ch, i)
+		}
+		if ch == '!' {
+			break
+		}
+		if ch == '$' {
+			y += n
+			x = 0
+			continue
+		}
+		if ch == ' ' || ch == '\t' {
+			continue
+		}
+		if ch >= 'p' && ch <= 'y' {
+			if prefix != 0 {
+				return nil, nil, fmt.Errorf("rle: invalid state prefix %q at offset %d", ch, i)
+			}
+			prefix = ch
+			count = n
+			if n == 1 {
+				count = 0
+			}
+			continue
+		}
+		state, ok := rleCharState(prefix, ch)
+		prefix = 0
+		if !ok {
+			return nil, nil, fmt.Errorf("rle: invalid cell %q at offset %d", ch, i)
+		}
+		if x+n > header.Width || y >= header.Height {
+			return nil, nil, fmt.Errorf("rle: cells outside of the %dx%d pattern at offset %d", header.Width, header.Height, i)
+		}
+		for ; n > 0; n-- {
+			g.SetCell(x, y, Cell(state))
+			x++
+		}
+	}
+	if prefix != 0 {
+		return nil, nil, fmt.Errorf("rle: invalid state prefix %q at the end", prefix)
+	}
+	return g, header, nil
+}
+
+// parseRLEHeader parses the "x = , y = , rule = " header line
+func parseRLEHeader(text string) (*RLEHeader, error) {
+	h := new(RLEHeader)
+	hasX, hasY := false, false
+	for _, m := range rleHeaderField.FindAllStringSubmatch(text, -1) {
+		value := strings.TrimSpace(m[2])
+		switch strings.ToLower(m[1]) {
+		case "x":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid width %q", value)
+			}
+			h.Width, hasX = n, true
+		case "y":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid height %q", value)
+			}
+			h.Height, hasY = n, true
+		case "rule":
+			h.Rule = value
+		}
+	}
+	if !hasX || !hasY {
+		return nil, fmt.Errorf("invalid header %q", text)
+	}
+	if h.Width < 0 || h.Height < 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", h.Width, h.Height)
+	}
+	// Empty patterns still need a cell in the grid
+	if h.Width == 0 || h.Height == 0 {
+		h.Width, h.Height = 1, 1
+	}
+	return h, nil
+}
+
+// rleCharState returns the state of an RLE cell character with its prefix
+func rleCharState(prefix, ch byte) (int, bool) {
+	if prefix == 0 {
+		switch {
+		case ch == 'b' || ch == '.':
+			return 0, true
+		case ch == 'o':
+			return 1, true
+		case ch >= 'A' && ch <= 'X':
+			return int(ch-'A') + 1, true
+		}
+		return 0, false
+	}
+	if ch < 'A' || ch > 'X' {
+		return 0, false
+	}
+	state := int(prefix-'p'+1)*24 + int(ch-'A') + 1
+	if state > 255 {
+		return 0, false
+	}
+	return state, true
+}
+
+// rleStateString returns the RLE characters of a state.
+// Two state patterns use b/o, multi-state patterns use ./A-X with prefixes.
+func rleStateString(state Cell, multiState bool) string {
+	if !multiState {
+		if state == 0 {
+			return "b"
+		}
+		return "o"
+	}
+	if state == 0 {
+		return "."
+	}
+	s := int(state) - 1
+	if s < 24 {
+		return string(rune('A' + s))
+	}
+	return string([]byte{byte('p' + s/24 - 1), byte('A' + s%24)})
+}
+
+// WriteRLE writes a grid in RLE format with the given rule in the header.
+// The rule is omitted from the header if empty.
+// Lines are wrapped at 70 characters as usual in RLE files.
+func WriteRLE(w io.Writer, g *Grid, rule string) error {
+	multiState := false
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.GetCell(x, y) > 1 {
+				multiState = true
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if rule != "" {
+		fmt.Fprintf(bw, "x = %d, y = %d, rule = %s\n", g.Width, g.Height, rule)
+	} else {
+		fmt.Fprintf(bw, "x = %d, y = %d\n", g.Width, g.Height)
+	}
+
+	lineLen := 0
+	emit := func(n int, s string) {
+		token := s
+		if n > 1 {
+			token = strconv.Itoa(n) + s
+		}
+		if lineLen+len(token) > 70 {
+			bw.WriteString("\n")
+			lineLen = 0
+		}
+		bw.WriteString(token)
+		lineLen += len(token)
+	}
+
+	row := 0
+	for y := 0; y < g.Height; y++ {
+		// Trailing dead cells of a row are not written
+		end := g.Width
+		for end > 0 && g.GetCell(end-1, y) == 0 {
+			end--
+		}
+		if end == 0 {
+			continue
+		}
+		if y > row {
+			emit(y-row, "$")
+			row = y
+		}
+		for x := 0; x < end; {
+			state := g.GetCell(x, y)
+			run := 1
+			for x+run < end && g.GetCell(x+run, y) == state {
+				run++
+			}
+			emit(run, rleStateString(state, multiState))
+			x += run
+		}
+	}
+	emit(1, "!")
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// ApplyRLERule sets the rules of the automaton from the rule of an RLE header.
+// The rule must be a rulestring with the same number of states as the automaton.
+func ApplyRLERule(c *Cella2d, h *RLEHeader) error {
+	if h.Rule == "" {
+		return fmt.Errorf("rle: pattern has no rule")
+	}
+	// Golly appends the topology to the rule, e.g. "B3/S23:T20,20"
+	rule := strings.SplitN(h.Rule, ":", 2)[0]
+	rs, err := ParseRulestring(rule)
+	if err != nil {
+		return fmt.Errorf("rle: %w", err)
+	}
+	if rs.NumStates != c.NumStates {
+		return fmt.Errorf("rle: rule %s has %d states, automaton has %d", h.Rule, rs.NumStates, c.NumStates)
+	}
+	c.SetRules(rs.Rules())
+	return nil
+}
@@ -0,0 +1,74 @@
+package cella
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadRLEGlider(t *testing.T) {
+	g, h, err := ReadRLE(strings.NewReader("#N Glider\nx = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 3 || h.Height != 3 || h.Rule != "B3/S23" {
+		t.Fatalf("RLE header does not match: %+v", h)
+	}
+	glider := NewGrid(3, 3)
+	glider.SetCell(1, 0, 1)
+	glider.SetCell(2, 1, 1)
+	glider.SetCell(0, 2, 1)
+	glider.SetCell(1, 2, 1)
+	glider.SetCell(2, 2, 1)
+	if !EqualsGrid(g, glider) {
+		t.Fatal("RLE glider does not match")
+	}
+
+	ca := NewCella2d(3, 3, 2)
+	if err := ApplyRLERule(ca, h); err != nil {
+		t.Fatal(err)
+	}
+	if len(ca.Rules) != 3 {
+		t.Fatalf("RLE rule created %d rules", len(ca.Rules))
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	g := NewGrid(30, 4)
+	g.SetCell(0, 0, 1)
+	g.SetCell(29, 0, 2)
+	g.SetCell(5, 3, 30)
+	g.SetCell(6, 3, 30)
+	var buf bytes.Buffer
+	if err := WriteRLE(&buf, g, "B2/S/C31"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "x = 30, y = 4, rule = B2/S/C31\nA28.B3$5.2pF!") {
+		t.Fatalf("RLE output does not match:\n%s", buf.String())
+	}
+	read, _, err := ReadRLE(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualsGrid(g, read) {
+		t.Fatal("RLE round trip does not match")
+	}
+}
+
+func TestReadRLEInvalid(t *testing.T) {
+	inputs := []string{
+		"bo$ob!",
+		"x = 2, y = 2\n3o!",
+		"x = 2, y = 2\nbz!",
+		"x = 0, y = -3\n!",
+		"x = -1, y = 0\n!",
+		"x = 2, y = 2\nApA$p!",
+		"x = 2, y = 2\nApA$p",
+		"x = 2, y = 2\nAp$A!",
+	}
+	for _, input := range inputs {
+		if _, _, err := ReadRLE(strings.NewReader(input)); err == nil {
+			t.Fatalf("RLE %q should send an error", input)
+		}
+	}
+}
@@ -13,6 +13,8 @@ type Rule2d struct {
 	state         Cell                   // State to change to
 	neighbourhood map[string]interface{} // Neighbourhood values used in the condition (neighbours states and total cells in each state)
 	eval          *goval.Evaluator       // Evaluator for the condition
+	match         func([][]Cell) bool    // Go function used as condition instead of the expression
+	neighbours    [][]Cell               // Neighbourhood used by the match function
 }
 
 // New creates a new rule by setting the condition and the state
@@ -27,6 +29,30 @@ func NewRule2d(condition string, state Cell, numStates int) *Rule2d {
 	return r
 }
 
+// NewRule2dFunc creates a new rule whose condition is a Go function
+// evaluated over the 3x3 neighbourhood instead of an expression.
+// The description is returned by GetCondition.
+func NewRule2dFunc(description string, state Cell, match func(neighbours [][]Cell) bool) *Rule2d {
+	r := new(Rule2d)
+	r.condition = description
+	r.state = state
+	r.match = match
+	return r
+}
+
+// Names of the variables of the neighbourhood, precalculated because they
+// are used for every cell of every generation
+var (
+	neighbourNames = [3][3]string{{"n00", "n01", "n02"}, {"n10", "n11", "n12"}, {"n20", "n21", "n22"}}
+	stateNames     [256]string
+)
+
+func init() {
+	for i := range stateNames {
+		stateNames[i] = fmt.Sprintf("s%d", i)
+	}
+}
+
 // initNeighbourhood initializes the neighbourhood used in the condition.
 // Given the number of states, it will create a variable for each state (s0, s1, ...)
 // and a variable for each cell in the neighbourhood (n00, n01, n02, n10, n11, n12, n20, n21, n22)
@@ -47,8 +73,7 @@ func (r *Rule2d) initNeighbourhood(numStates int) {
 func (r *Rule2d) setNeighboursState(neighbours [][]Cell) {
 	for y := range neighbours {
 		for x := range neighbours[y] {
-			neighbourName := fmt.Sprintf("n%d%d", y, x)
-			r.neighbourhood[neighbourName] = int(neighbours[y][x])
+			r.neighbourhood[neighbourNames[y][x]] = int(neighbours[y][x])
 		}
 	}
 }
@@ -59,19 +84,29 @@ func (r *Rule2d) countNeighboursState(neighbours [][]Cell) {
 	for varName := range r.neighbourhood {
 		r.neighbourhood[varName] = 0
 	}
+	// States without a variable are greater than the number of states of
+	// the rule and are not counted
 	for y := range neighbours {
 		for x := range neighbours[y] {
-			stateName := fmt.Sprintf("s%d", neighbours[y][x])
-			r.neighbourhood[stateName] = r.neighbourhood[stateName].(int) + 1
+			stateName := stateNames[neighbours[y][x]]
+			if n, ok := r.neighbourhood[stateName].(int); ok {
+				r.neighbourhood[stateName] = n + 1
+			}
 		}
 	}
 	// Remove the cell itself from the count
-	stateName := fmt.Sprintf("s%d", neighbours[1][1])
-	r.neighbourhood[stateName] = r.neighbourhood[stateName].(int) - 1
+	stateName := stateNames[neighbours[1][1]]
+	if n, ok := r.neighbourhood[stateName].(int); ok {
+		r.neighbourhood[stateName] = n - 1
+	}
 }
 
 // SetNeighbourhood sets the neighbourhood used in the condition
 func (r *Rule2d) SetNeighbourhood(neighbours [][]Cell) {
+	if r.match != nil {
+		r.neighbours = neighbours
+		return
+	}
 	r.countNeighboursState(neighbours)
 	r.setNeighboursState(neighbours)
 }
@@ -86,8 +121,20 @@ func (r *Rule2d) GetCondition() string {
 	return r.condition
 }
 
+// IsExpression returns true if the condition of the rule is an expression
+// and false if it is a Go function
+func (r *Rule2d) IsExpression() bool {
+	return r.match == nil
+}
+
 // CheckCondition checks if the condition is true
 func (r *Rule2d) CheckCondition() (bool, error) {
+	if r.match != nil {
+		if r.neighbours == nil {
+			return false, fmt.Errorf("condition {%s} has no neighbourhood set", r.condition)
+		}
+		return r.match(r.neighbours), nil
+	}
 	res, err := r.eval.Evaluate(r.condition, r.neighbourhood, nil)
 	if err != nil {
 		return false, err
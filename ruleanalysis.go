@@ -0,0 +1,330 @@
+package cella
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxTableConfigurations limits the number of neighbourhoods enumerated by
+// a transition table, enough for 4 states
+const maxTableConfigurations = 1 << 20
+
+// TransitionTable is the transition function defined by a list of rules
+// over every 3x3 neighbourhood. A neighbourhood is indexed by its cells
+// n00, n01, ..., n22 as the digits, from least significant, of a number
+// in base NumStates.
+type TransitionTable struct {
+	NumStates int
+	Next      []Cell    // New state of the center cell of each neighbourhood
+	Rule      []int32   // Index of the rule applied to each neighbourhood, -1 if none
+	Matches   [][]int32 // Indexes of the rules that matched each neighbourhood, including the rule applied
+}
+
+// NewTransitionTable evaluates the rules over every 3x3 neighbourhood of
+// cells with numStates states
+func NewTransitionTable(rules []*Rule2d, numStates int) (*TransitionTable, error) {
+	size := 1
+	for i := 0; i < 9; i++ {
+		size *= numStates
+		if size > maxTableConfigurations {
+			return nil, fmt.Errorf("rules: %d states have more than %d neighbourhoods", numStates, maxTableConfigurations)
+		}
+	}
+	t := &TransitionTable{
+		NumStates: numStates,
+		Next:      make([]Cell, size),
+		Rule:      make([]int32, size),
+		Matches:   make([][]int32, size),
+	}
+	neighbours := make([][]Cell, 3)
+	for i := range neighbours {
+		neighbours[i] = make([]Cell, 3)
+	}
+	for i := 0; i < size; i++ {
+		t.neighbourhood(i, neighbours)
+		t.Rule[i] = -1
+		t.Next[i] = neighbours[1][1]
+		for r, rule := range rules {
+			rule.SetNeighbourhood(neighbours)
+			ok, err := rule.CheckCondition()
+			if err != nil {
+				return nil, fmt.Errorf("rules: rule %d: %w", r, err)
+			}
+			if !ok {
+				continue
+			}
+			if t.Rule[i] < 0 {
+				t.Rule[i] = int32(r)
+				t.Next[i] = rule.GetState()
+			}
+			t.Matches[i] = append(t.Matches[i], int32(r))
+		}
+	}
+	return t, nil
+}
+
+// Len returns the number of neighbourhoods of the table
+func (t *TransitionTable) Len() int {
+	return len(t.Next)
+}
+
+// neighbourhood decodes the cells of the neighbourhood of an index
+func (t *TransitionTable) neighbourhood(i int, neighbours [][]Cell) {
+	for p := 0; p < 9; p++ {
+		neighbours[p/3][p%3] = Cell(i % t.NumStates)
+		i /= t.NumStates
+	}
+}
+
+// Neighbourhood returns the cells of the neighbourhood of an index
+func (t *TransitionTable) Neighbourhood(i int) [][]Cell {
+	neighbours := make([][]Cell, 3)
+	for y := range neighbours {
+		neighbours[y] = make([]Cell, 3)
+	}
+	t.neighbourhood(i, neighbours)
+	return neighbours
+}
+
+// Index returns the index of a neighbourhood
+func (t *TransitionTable) Index(neighbours [][]Cell) int {
+	i := 0
+	for p := 8; p >= 0; p-- {
+		i = i*t.NumStates + int(neighbours[p/3][p%3])
+	}
+	return i
+}
+
+// symmetries3x3 are the rotations and reflections of the 3x3 neighbourhood
+// as the position, in row order, taken by each position
+var symmetries3x3 = func() [][9]int {
+	var syms [][9]int
+	transforms := []func(x, y int) (int, int){
+		func(x, y int) (int, int) { return x, y },
+		func(x, y int) (int, int) { return 2 - y, x },
+		func(x, y int) (int, int) { return 2 - x, 2 - y },
+		func(x, y int) (int, int) { return y, 2 - x },
+		func(x, y int) (int, int) { return 2 - x, y },
+		func(x, y int) (int, int) { return x, 2 - y },
+		func(x, y int) (int, int) { return y, x },
+		func(x, y int) (int, int) { return 2 - y, 2 - x },
+	}
+	for _, tr := range transforms {
+		var perm [9]int
+		for p := 0; p < 9; p++ {
+			x, y := tr(p%3, p/3)
+			perm[p] = y*3 + x
+		}
+		syms = append(syms, perm)
+	}
+	return syms
+}()
+
+// permute returns the index of the neighbourhood with its cells moved by the permutation
+func (t *TransitionTable) permute(i int, perm *[9]int) int {
+	var digits [9]int
+	for p := 0; p < 9; p++ {
+		digits[perm[p]] = i % t.NumStates
+		i /= t.NumStates
+	}
+	j := 0
+	for p := 8; p >= 0; p-- {
+		j = j*t.NumStates + digits[p]
+	}
+	return j
+}
+
+// IsIsotropic returns true if the new state does not change when the
+// neighbourhood is rotated or reflected
+func (t *TransitionTable) IsIsotropic() bool {
+	for i := range t.Next {
+		for s := 1; s < len(symmetries3x3); s++ {
+			if t.Next[t.permute(i, &symmetries3x3[s])] != t.Next[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// totalisticKey returns the number of cells in each state of the
+// neighbourhood, followed by the center state if outer is set, in which
+// case the center is not counted
+func (t *TransitionTable) totalisticKey(i int, outer bool) string {
+	key := make([]byte, t.NumStates+1)
+	for p := 0; p < 9; p++ {
+		s := i % t.NumStates
+		i /= t.NumStates
+		if p == 4 && outer {
+			key[t.NumStates] = byte(s)
+			continue
+		}
+		key[s]++
+	}
+	return string(key)
+}
+
+// totalistic checks that the new state is the same for all the
+// neighbourhoods with the same totalistic key
+func (t *TransitionTable) totalistic(outer bool) bool {
+	seen := make(map[string]Cell)
+	for i, next := range t.Next {
+		key := t.totalisticKey(i, outer)
+		if prev, ok := seen[key]; ok && prev != next {
+			return false
+		}
+		seen[key] = next
+	}
+	return true
+}
+
+// IsOuterTotalistic returns true if the new state only depends on the state
+// of the center and the number of neighbours in each state
+func (t *TransitionTable) IsOuterTotalistic() bool {
+	return t.totalistic(true)
+}
+
+// IsTotalistic returns true if the new state only depends on the number of
+// cells in each state in the whole neighbourhood, center included
+func (t *TransitionTable) IsTotalistic() bool {
+	return t.totalistic(false)
+}
+
+// IsQuiescent returns true if a cell in state 0 surrounded by cells in
+// state 0 stays in state 0
+func (t *TransitionTable) IsQuiescent() bool {
+	return t.Next[0] == 0
+}
+
+// RuleCoverage is the analysis of a rule over every neighbourhood
+type RuleCoverage struct {
+	Index       int   // Index of the rule
+	Applied     int   // Neighbourhoods where the rule is applied
+	Matched     int   // Neighbourhoods where the condition of the rule is true
+	Unreachable bool  // The rule is never applied
+	ShadowedBy  []int // Earlier rules applied where this rule matched
+}
+
+// RuleOverlap is a pair of rules matching the same neighbourhoods
+type RuleOverlap struct {
+	First, Second int  // Indexes of the rules, the first one is applied
+	Count         int  // Neighbourhoods matched by both rules
+	Conflicting   bool // The rules change to different states
+}
+
+// RuleAnalysis is the static analysis of a list of rules
+type RuleAnalysis struct {
+	Table           *TransitionTable
+	Rules           []RuleCoverage
+	Overlaps        []RuleOverlap
+	Gaps            []int // Neighbourhoods where no rule is applied and the cell keeps its state
+	Isotropic       bool  // The rules do not change with rotations and reflections
+	Totalistic      bool  // The rules only depend on the number of cells in each state
+	OuterTotalistic bool  // The rules only depend on the center and the number of neighbours in each state
+	Quiescent       bool  // State 0 surrounded by state 0 stays in state 0
+}
+
+// AnalyzeRules enumerates every 3x3 neighbourhood of cells with numStates
+// states and reports the rules never applied, the rules that overlap, the
+// neighbourhoods without a rule and the properties of the transition function
+func AnalyzeRules(rules []*Rule2d, numStates int) (*RuleAnalysis, error) {
+	t, err := NewTransitionTable(rules, numStates)
+	if err != nil {
+		return nil, err
+	}
+	a := &RuleAnalysis{
+		Table:           t,
+		Rules:           make([]RuleCoverage, len(rules)),
+		Isotropic:       t.IsIsotropic(),
+		Totalistic:      t.IsTotalistic(),
+		OuterTotalistic: t.IsOuterTotalistic(),
+		Quiescent:       t.IsQuiescent(),
+	}
+	shadowedBy := make([]map[int]bool, len(rules))
+	overlaps := make(map[[2]int]int)
+	for i, matches := range t.Matches {
+		if t.Rule[i] < 0 {
+			a.Gaps = append(a.Gaps, i)
+			continue
+		}
+		applied := int(t.Rule[i])
+		a.Rules[applied].Applied++
+		for _, m := range matches {
+			a.Rules[m].Matched++
+			if int(m) == applied {
+				continue
+			}
+			if shadowedBy[m] == nil {
+				shadowedBy[m] = make(map[int]bool)
+			}
+			shadowedBy[m][applied] = true
+			overlaps[[2]int{applied, int(m)}]++
+		}
+	}
+	for i := range a.Rules {
+		rc := &a.Rules[i]
+		rc.Index = i
+		rc.Unreachable = rc.Applied == 0
+		for r := 0; r < i; r++ {
+			if shadowedBy[i][r] {
+				rc.ShadowedBy = append(rc.ShadowedBy, r)
+			}
+		}
+	}
+	for first := range rules {
+		for second := first + 1; second < len(rules); second++ {
+			if n := overlaps[[2]int{first, second}]; n > 0 {
+				a.Overlaps = append(a.Overlaps, RuleOverlap{
+					First:       first,
+					Second:      second,
+					Count:       n,
+					Conflicting: rules[first].GetState() != rules[second].GetState(),
+				})
+			}
+		}
+	}
+	return a, nil
+}
+
+// AnalyzeRules analyzes the rules of the automaton
+func (c *Cella2d) AnalyzeRules() (*RuleAnalysis, error) {
+	return AnalyzeRules(c.Rules, c.NumStates)
+}
+
+// Unreachable returns the indexes of the rules that are never applied
+func (a *RuleAnalysis) Unreachable() []int {
+	var idx []int
+	for _, rc := range a.Rules {
+		if rc.Unreachable {
+			idx = append(idx, rc.Index)
+		}
+	}
+	return idx
+}
+
+// String returns a report of the analysis
+func (a *RuleAnalysis) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "neighbourhoods: %d\n", a.Table.Len())
+	for _, rc := range a.Rules {
+		fmt.Fprintf(&sb, "rule %d: applied %d, matched %d", rc.Index, rc.Applied, rc.Matched)
+		switch {
+		case rc.Unreachable && rc.Matched > 0:
+			fmt.Fprintf(&sb, ", unreachable, shadowed by %v", rc.ShadowedBy)
+		case rc.Unreachable:
+			sb.WriteString(", unreachable, never matches")
+		}
+		sb.WriteByte('\n')
+	}
+	for _, o := range a.Overlaps {
+		fmt.Fprintf(&sb, "rules %d and %d overlap in %d neighbourhoods", o.First, o.Second, o.Count)
+		if o.Conflicting {
+			sb.WriteString(" with different states")
+		}
+		sb.WriteByte('\n')
+	}
+	fmt.Fprintf(&sb, "neighbourhoods without rule: %d\n", len(a.Gaps))
+	fmt.Fprintf(&sb, "isotropic: %t\ntotalistic: %t\nouter totalistic: %t\nquiescent: %t\n",
+		a.Isotropic, a.Totalistic, a.OuterTotalistic, a.Quiescent)
+	return sb.String()
+}
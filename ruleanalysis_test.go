@@ -0,0 +1,63 @@
+package cella
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeRules(t *testing.T) {
+	ca := newGameOfLife(3, 3)
+	ca.SetRules(append(ca.Rules, NewRule2d("n11 == 1", 1, 2)))
+	a, err := ca.AnalyzeRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Table.Len() != 512 || len(a.Gaps) != 0 {
+		t.Fatalf("Table has %d neighbourhoods and %d gaps", a.Table.Len(), len(a.Gaps))
+	}
+	if !a.Isotropic || !a.OuterTotalistic || a.Totalistic || !a.Quiescent {
+		t.Fatalf("Properties do not match:\n%s", a)
+	}
+	if !reflect.DeepEqual(a.Unreachable(), []int{3}) || !reflect.DeepEqual(a.Rules[3].ShadowedBy, []int{0, 2}) {
+		t.Fatalf("Unreachable rules do not match:\n%s", a)
+	}
+	// Survival with 2 or 3 of the 8 neighbours alive
+	if a.Rules[0].Applied != 28+56 {
+		t.Fatalf("Rule 0 applied %d times", a.Rules[0].Applied)
+	}
+	if len(a.Overlaps) != 4 || a.Overlaps[1] != (RuleOverlap{0, 3, 84, false}) || a.Overlaps[3] != (RuleOverlap{2, 3, 256 - 84, true}) {
+		t.Fatalf("Overlaps do not match: %v", a.Overlaps)
+	}
+
+	neighbours := [][]Cell{{0, 1, 0}, {0, 0, 1}, {1, 0, 0}}
+	if i := a.Table.Index(neighbours); !reflect.DeepEqual(a.Table.Neighbourhood(i), neighbours) || a.Table.Next[i] != 1 {
+		t.Fatal("Neighbourhood index does not match")
+	}
+}
+
+func TestAnalyzeRulesProperties(t *testing.T) {
+	tests := []struct {
+		rules                        []*Rule2d
+		gaps                         int
+		isotropic, totalistic, quiet bool
+	}{
+		// Only births, the rest of the cells keep their state
+		{[]*Rule2d{NewRule2d("n11 == 0 && s1 == 3", 1, 2)}, 512 - 56, true, false, true},
+		// Copy the north west neighbour
+		{[]*Rule2d{NewRule2d("n00 == 1", 1, 2), NewRule2d("n00 == 0", 0, 2)}, 0, false, false, true},
+		// Majority of the whole neighbourhood, inverted so it is not quiescent
+		{[]*Rule2d{NewRule2d("s1 + n11 >= 5", 0, 2), NewRule2d("0 == 0", 1, 2)}, 0, true, true, false},
+	}
+	for i, test := range tests {
+		a, err := AnalyzeRules(test.rules, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a.Gaps) != test.gaps || a.Isotropic != test.isotropic || a.Totalistic != test.totalistic || a.Quiescent != test.quiet {
+			t.Fatalf("Analysis %d does not match:\n%s", i, a)
+		}
+	}
+	if _, err := AnalyzeRules(nil, 5); err == nil {
+		t.Fatal("Table of 5 states should be too big")
+	}
+}
@@ -0,0 +1,159 @@
+package cella
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleStats counts which rules are applied to each cell while the
+// automaton runs. Tracking rules is slower than running the automaton since
+// every rule is evaluated for every cell, to know when a rule matched a cell
+// but an earlier rule was applied.
+type RuleStats struct {
+	Rules      []*Rule2d // Rules tracked, the rules of the automaton in the last generation
+	Fired      [][]int   // Index of the rule applied to each cell in the last generation, as [y][x], -1 if none
+	Generation []int     // Number of cells each rule was applied to in the last generation
+	History    [][]int   // Generation counts of the last MaxHistory generations tracked, from the oldest
+	MaxHistory int       // Maximum number of generations kept in History, 0 keeps none
+	Firings    []int     // Number of cells each rule was applied to in all the generations
+	Matches    []int     // Number of cells each rule matched in all the generations, applied or not
+	Unmatched  int       // Number of cells no rule was applied to in all the generations
+}
+
+// defaultRuleHistory is the number of generations kept in the history of
+// the rule statistics
+const defaultRuleHistory = 1024
+
+// TrackRules starts counting the rules applied in each generation,
+// returning the statistics updated by NextGeneration. The history keeps
+// the last 1024 generations, change MaxHistory to keep more or less.
+func (c *Cella2d) TrackRules() *RuleStats {
+	s := &RuleStats{Fired: newLayer(c.Width, c.Height), MaxHistory: defaultRuleHistory}
+	s.resize(c.Rules)
+	c.ruleStats = s
+	return s
+}
+
+// StopTrackingRules stops counting the rules applied
+func (c *Cella2d) StopTrackingRules() {
+	c.ruleStats = nil
+}
+
+// resize resets the counts if the rules changed
+func (s *RuleStats) resize(rules []*Rule2d) {
+	if len(rules) == len(s.Rules) {
+		same := true
+		for i := range rules {
+			same = same && rules[i] == s.Rules[i]
+		}
+		if same {
+			return
+		}
+	}
+	s.Rules = rules
+	s.Generation = make([]int, len(rules))
+	s.Firings = make([]int, len(rules))
+	s.Matches = make([]int, len(rules))
+	s.History = nil
+	s.Unmatched = 0
+}
+
+// begin starts the counts of a new generation
+func (s *RuleStats) begin(c *Cella2d) {
+	s.resize(c.Rules)
+	// The size of the automaton can change, as when a snapshot is loaded
+	if len(s.Fired) != c.Height || (c.Height > 0 && len(s.Fired[0]) != c.Width) {
+		s.Fired = newLayer(c.Width, c.Height)
+	}
+	if s.MaxHistory <= 0 {
+		s.History = nil
+		s.Generation = make([]int, len(s.Rules))
+		return
+	}
+	if len(s.History) > s.MaxHistory {
+		s.History = s.History[len(s.History)-s.MaxHistory:]
+	}
+	if len(s.History) < s.MaxHistory {
+		s.Generation = make([]int, len(s.Rules))
+		s.History = append(s.History, s.Generation)
+		return
+	}
+	// The counts of the oldest generation are reused for the new one
+	s.Generation = s.History[0]
+	for i := range s.Generation {
+		s.Generation[i] = 0
+	}
+	copy(s.History, s.History[1:])
+	s.History[len(s.History)-1] = s.Generation
+}
+
+// record counts the rule applied to a cell, and evaluates the rules after
+// it to know which ones also matched. The neighbourhood of the cell must be
+// in neighbours.
+func (s *RuleStats) record(c *Cella2d, x, y, rule int, neighbours [][]Cell) error {
+	s.Fired[y][x] = rule
+	if rule < 0 {
+		s.Unmatched++
+		return nil
+	}
+	s.Generation[rule]++
+	s.Firings[rule]++
+	s.Matches[rule]++
+	for i := rule + 1; i < len(s.Rules); i++ {
+		r := s.Rules[i]
+		r.SetNeighbourhood(neighbours)
+		ok, err := r.CheckCondition()
+		if err != nil {
+			return c.ruleError(i, x, y, err)
+		}
+		if ok {
+			s.Matches[i]++
+		}
+	}
+	return nil
+}
+
+// RuleReport is the summary of a rule in the statistics
+type RuleReport struct {
+	Index      int    // Index of the rule in the rules of the automaton
+	Condition  string // Condition of the rule
+	State      Cell   // State the rule changes to
+	Firings    int    // Number of cells the rule was applied to
+	Matches    int    // Number of cells the rule matched
+	NeverFired bool   // The rule was never applied
+	Shadowed   bool   // The rule matched cells but earlier rules were always applied
+}
+
+// Report returns the summary of each rule
+func (s *RuleStats) Report() []RuleReport {
+	reports := make([]RuleReport, len(s.Rules))
+	for i, r := range s.Rules {
+		reports[i] = RuleReport{
+			Index:      i,
+			Condition:  r.GetCondition(),
+			State:      r.GetState(),
+			Firings:    s.Firings[i],
+			Matches:    s.Matches[i],
+			NeverFired: s.Firings[i] == 0,
+			Shadowed:   s.Firings[i] == 0 && s.Matches[i] > 0,
+		}
+	}
+	return reports
+}
+
+// String returns the report of the rules with one rule per line
+func (s *RuleStats) String() string {
+	var sb strings.Builder
+	for _, r := range s.Report() {
+		fmt.Fprintf(&sb, "%3d  -> %-3d fired %-8d matched %-8d {%s}", r.Index, r.State, r.Firings, r.Matches, r.Condition)
+		switch {
+		case r.Shadowed:
+			sb.WriteString("  shadowed")
+		case r.NeverFired:
+			sb.WriteString("  never fired")
+		}
+		sb.WriteByte('\n')
+	}
+	fmt.Fprintf(&sb, "cells without rule: %d\n", s.Unmatched)
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package cella
+
+import (
+	"testing"
+)
+
+func TestRuleStats(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	// A rule after the catch-all rule is shadowed, a rule for state 2 never matches
+	ca.SetRules(append(ca.Rules, NewRule2d("n11 == 1", 1, 2), NewRule2d("s1 == 8", 1, 2)))
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	stats := ca.TrackRules()
+	if err := ca.Run(2); err != nil {
+		t.Fatal(err)
+	}
+	// Each generation the center survives, two cells are born and the rest die
+	if len(stats.History) != 2 || stats.Generation[0] != 1 || stats.Generation[1] != 2 || stats.Generation[2] != 22 {
+		t.Fatalf("Generation counts do not match: %v", stats.History)
+	}
+	if stats.Fired[2][2] != 0 || stats.Fired[0][0] != 2 || stats.Fired[2][1] != 1 {
+		t.Fatalf("Fired rules do not match: %v", stats.Fired)
+	}
+	r := stats.Report()
+	if r[0].Firings != 2 || r[3].Matches != 6 || !r[3].Shadowed || !r[4].NeverFired || r[4].Shadowed || stats.Unmatched != 0 {
+		t.Fatalf("Report does not match:\n%s", stats)
+	}
+
+	ca.StopTrackingRules()
+	if err := ca.Step(); err != nil || len(stats.History) != 2 {
+		t.Fatal("Rules were tracked after stopping")
+	}
+}
+
+func TestRuleStatsResize(t *testing.T) {
+	ca := newGameOfLife(3, 3)
+	stats := ca.TrackRules()
+	big := newGameOfLife(6, 4)
+	ca.Width, ca.Height = big.Width, big.Height
+	ca.SetInitGrid(big.InitGrid)
+	ca.SetNextGrid(big.NextGrid)
+	ca.SetCellsPerState(big.CellsPerState)
+	if err := ca.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Fired) != 4 || len(stats.Fired[0]) != 6 {
+		t.Fatalf("Fired is %dx%d after resizing to 6x4", len(stats.Fired[0]), len(stats.Fired))
+	}
+}
+
+func TestRuleStatsHistoryLimit(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	for x := 1; x < 4; x++ {
+		ca.InitGrid.SetCell(x, 2, 1)
+	}
+	stats := ca.TrackRules()
+	stats.MaxHistory = 3
+	if err := ca.Run(5); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.History) != 3 || &stats.History[2][0] != &stats.Generation[0] || stats.Firings[1] != 10 {
+		t.Fatalf("History limited to 3 generations does not match: %v", stats.History)
+	}
+	for _, counts := range stats.History {
+		if counts[0] != 1 || counts[1] != 2 || counts[2] != 22 {
+			t.Fatalf("History counts do not match: %v", stats.History)
+		}
+	}
+
+	stats.MaxHistory = 0
+	if err := ca.Step(); err != nil || stats.History != nil || stats.Generation[1] != 2 {
+		t.Fatalf("Disabled history does not match: %v", stats.History)
+	}
+}
@@ -0,0 +1,224 @@
+package cella
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Neighbourhood is the set of cells around a cell counted by a rulestring
+type Neighbourhood uint8
+
+const (
+	NeighbourhoodMoore      Neighbourhood = iota // The 8 surrounding cells
+	NeighbourhoodVonNeumann                      // The 4 orthogonally adjacent cells
+)
+
+// ParseNeighbourhood parses a neighbourhood name ("moore" or "vonneumann")
+func ParseNeighbourhood(name string) (Neighbourhood, error) {
+	switch strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)) {
+	case "", "moore":
+		return NeighbourhoodMoore, nil
+	case "vonneumann":
+		return NeighbourhoodVonNeumann, nil
+	}
+	return 0, fmt.Errorf("unknown neighbourhood %q", name)
+}
+
+// String returns the name of the neighbourhood
+func (n Neighbourhood) String() string {
+	switch n {
+	case NeighbourhoodMoore:
+		return "moore"
+	case NeighbourhoodVonNeumann:
+		return "vonneumann"
+	}
+	return fmt.Sprintf("Neighbourhood(%d)", uint8(n))
+}
+
+// Rulestring is a life-like or generations rule written as B/S notation.
+// State 0 is dead and state 1 is alive, states 2 and above are dying
+// states of a generations rule.
+type Rulestring struct {
+	Birth         []int         // Number of alive neighbours for a dead cell to be born
+	Survival      []int         // Number of alive neighbours for an alive cell to survive
+	NumStates     int           // Number of states, greater than 2 for generations rules
+	Neighbourhood Neighbourhood // Neighbourhood used to count alive neighbours
+}
+
+// ParseRulestring parses a rulestring in any of the usual notations:
+// "B3/S23", "B3S23", "23/3" (S/B), "B2/S/C3" or "/2/3" (generations, S/B/C).
+// A trailing "V" selects the von Neumann neighbourhood, e.g. "B1/S1V".
+func ParseRulestring(rule string) (*Rulestring, error) {
+	rs := &Rulestring{NumStates: 2, Neighbourhood: NeighbourhoodMoore}
+	s := strings.TrimSpace(rule)
+	if s == "" {
+		return nil, fmt.Errorf("empty rulestring")
+	}
+	switch s[len(s)-1] {
+	case 'V', 'v':
+		rs.Neighbourhood = NeighbourhoodVonNeumann
+		s = s[:len(s)-1]
+	case 'M', 'm':
+		s = s[:len(s)-1]
+	}
+	maxCount := 8
+	if rs.Neighbourhood == NeighbourhoodVonNeumann {
+		maxCount = 4
+	}
+
+	// Rulestrings without separators, like "B3S23"
+	if !strings.Contains(s, "/") {
+		if i := strings.IndexAny(s, "Ss"); i > 0 {
+			s = s[:i] + "/" + s[i:]
+		}
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("rulestring %q: expected 2 or 3 parts separated by '/'", rule)
+	}
+	var birth, survival, states string
+	var hasBirth, hasSurvival bool
+	for i, p := range parts {
+		if p != "" {
+			switch p[0] {
+			case 'B', 'b':
+				birth, hasBirth = p[1:], true
+				continue
+			case 'S', 's':
+				survival, hasSurvival = p[1:], true
+				continue
+			case 'C', 'c', 'G', 'g':
+				states = p[1:]
+				continue
+			}
+		}
+		// Parts without a letter follow the S/B/C order
+		switch i {
+		case 0:
+			survival, hasSurvival = p, true
+		case 1:
+			birth, hasBirth = p, true
+		case 2:
+			states = p
+		}
+	}
+	if !hasBirth || !hasSurvival {
+		return nil, fmt.Errorf("rulestring %q: missing birth or survival conditions", rule)
+	}
+	var err error
+	if rs.Birth, err = parseRulestringCounts(birth, maxCount); err != nil {
+		return nil, fmt.Errorf("rulestring %q: birth: %w", rule, err)
+	}
+	if rs.Survival, err = parseRulestringCounts(survival, maxCount); err != nil {
+		return nil, fmt.Errorf("rulestring %q: survival: %w", rule, err)
+	}
+	if states != "" {
+		n, err := strconv.Atoi(states)
+		if err != nil || n < 2 || n > 256 {
+			return nil, fmt.Errorf("rulestring %q: invalid number of states %q", rule, states)
+		}
+		rs.NumStates = n
+	}
+	return rs, nil
+}
+
+// parseRulestringCounts parses a list of neighbour counts like "236"
+func parseRulestringCounts(s string, maxCount int) ([]int, error) {
+	seen := make(map[int]bool)
+	counts := []int{}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return nil, fmt.Errorf("invalid character %q", ch)
+		}
+		n := int(ch - '0')
+		if n > maxCount {
+			return nil, fmt.Errorf("count %d greater than %d", n, maxCount)
+		}
+		if !seen[n] {
+			seen[n] = true
+			counts = append(counts, n)
+		}
+	}
+	sort.Ints(counts)
+	return counts, nil
+}
+
+// check checks that the counts are possible in the neighbourhood of the
+// rulestring
+func (rs *Rulestring) check() error {
+	maxCount := 8
+	if rs.Neighbourhood == NeighbourhoodVonNeumann {
+		maxCount = 4
+	}
+	for _, n := range rs.Birth {
+		if n > maxCount {
+			return fmt.Errorf("birth count %d greater than %d in the %s neighbourhood", n, maxCount, rs.Neighbourhood)
+		}
+	}
+	for _, n := range rs.Survival {
+		if n > maxCount {
+			return fmt.Errorf("survival count %d greater than %d in the %s neighbourhood", n, maxCount, rs.Neighbourhood)
+		}
+	}
+	return nil
+}
+
+// String returns the rulestring in B/S notation
+func (rs *Rulestring) String() string {
+	var sb strings.Builder
+	sb.WriteString("B")
+	for _, n := range rs.Birth {
+		sb.WriteString(strconv.Itoa(n))
+	}
+	sb.WriteString("/S")
+	for _, n := range rs.Survival {
+		sb.WriteString(strconv.Itoa(n))
+	}
+	if rs.NumStates > 2 {
+		sb.WriteString("/C")
+		sb.WriteString(strconv.Itoa(rs.NumStates))
+	}
+	if rs.Neighbourhood == NeighbourhoodVonNeumann {
+		sb.WriteString("V")
+	}
+	return sb.String()
+}
+
+// Rules creates the rules of the rulestring to be used in a Cella2d
+// with NumStates states
+func (rs *Rulestring) Rules() []*Rule2d {
+	count := "s1"
+	if rs.Neighbourhood == NeighbourhoodVonNeumann {
+		count = "((n01 == 1 ? 1 : 0) + (n10 == 1 ? 1 : 0) + (n12 == 1 ? 1 : 0) + (n21 == 1 ? 1 : 0))"
+	}
+	dying := Cell(0)
+	if rs.NumStates > 2 {
+		dying = 2
+	}
+
+	rules := []*Rule2d{}
+	if len(rs.Birth) > 0 {
+		rules = append(rules, NewRule2d("n11 == 0 && "+countCondition(count, rs.Birth), 1, rs.NumStates))
+	}
+	if len(rs.Survival) > 0 {
+		rules = append(rules, NewRule2d("n11 == 1 && "+countCondition(count, rs.Survival), 1, rs.NumStates))
+	}
+	rules = append(rules, NewRule2d("n11 == 1", dying, rs.NumStates))
+	// Dying states of generations rules advance until the cell is dead
+	for s := 2; s < rs.NumStates; s++ {
+		next := Cell((s + 1) % rs.NumStates)
+		rules = append(rules, NewRule2d(fmt.Sprintf("n11 == %d", s), next, rs.NumStates))
+	}
+	return rules
+}
+
+// countCondition creates a condition checking that count is one of the values
+func countCondition(count string, values []int) string {
+	conds := make([]string, len(values))
+	for i, v := range values {
+		conds[i] = fmt.Sprintf("%s == %d", count, v)
+	}
+	return "(" + strings.Join(conds, " || ") + ")"
+}
@@ -0,0 +1,184 @@
+package cella
+
+import (
+	"context"
+	"errors"
+)
+
+// StopReason is the reason why RunContext stopped
+type StopReason uint8
+
+const (
+	StopGenerations StopReason = iota // The number of generations was run
+	StopCancelled                     // The context was cancelled
+	StopDeadline                      // The deadline of the context was exceeded
+	StopEmpty                         // All the cells are in state 0
+	StopSteady                        // The population did not change for SteadyFor generations
+	StopRecurrence                    // The grid is equal to a previous generation
+	StopPredicate                     // The predicate returned true
+	StopError                         // A rule failed
+)
+
+// String returns a description of the reason
+func (r StopReason) String() string {
+	switch r {
+	case StopGenerations:
+		return "generations completed"
+	case StopCancelled:
+		return "cancelled"
+	case StopDeadline:
+		return "deadline exceeded"
+	case StopEmpty:
+		return "population zero"
+	case StopSteady:
+		return "population steady"
+	case StopRecurrence:
+		return "grid recurrence"
+	case StopPredicate:
+		return "predicate"
+	}
+	return "rule error"
+}
+
+// RunOptions are the stop conditions of RunContext.
+// The conditions are checked before each generation, including the first one.
+type RunOptions struct {
+	Generations       int              // Maximum number of generations, no limit if 0
+	StopWhenEmpty     bool             // Stop when all the cells are in state 0
+	SteadyFor         int              // Stop when the population does not change for this many generations, disabled if 0
+	StopOnRecurrence  bool             // Stop when the grid is equal to a previous generation
+	RecurrenceHistory int              // Maximum number of grid hashes kept, 65536 if not set
+	Predicate         func([]int) bool // Stop when it returns true for CellsPerState
+}
+
+// RunResult is the progress of RunContext when it stopped
+type RunResult struct {
+	Reason      StopReason // Reason why the run stopped
+	Generations int        // Generations run
+	Generation  int        // Generation of the automaton at the end
+	Period      int        // Generations since the grid appeared before, with StopRecurrence
+}
+
+// RunContext runs the automaton with Step until a stop condition is met
+// or the context is done, and then notifies the observers that the run
+// stopped. The result is returned with the error of the context or of the
+// rules, the automaton is left in the last generation calculated.
+// CellsPerState is kept up to date.
+func (c *Cella2d) RunContext(ctx context.Context, opts RunOptions) (*RunResult, error) {
+	defer c.notifyStop()
+	if opts.RecurrenceHistory <= 0 {
+		opts.RecurrenceHistory = 1 << 16
+	}
+	var history *recurrenceHistory
+	if opts.StopOnRecurrence {
+		history = &recurrenceHistory{max: opts.RecurrenceHistory}
+	}
+	// NextGeneration keeps the counts up to date after the first generation
+	c.CountCellsPerState()
+	res := new(RunResult)
+	population, steady := -1, 0
+	for {
+		res.Generation = c.Generation
+		if err := ctx.Err(); err != nil {
+			res.Reason = StopCancelled
+			if errors.Is(err, context.DeadlineExceeded) {
+				res.Reason = StopDeadline
+			}
+			return res, err
+		}
+
+		pop := c.Width*c.Height - c.CellsPerState[0]
+		if opts.StopWhenEmpty && pop == 0 {
+			res.Reason = StopEmpty
+			return res, nil
+		}
+		if pop == population {
+			steady++
+		} else {
+			population, steady = pop, 0
+		}
+		if opts.SteadyFor > 0 && steady >= opts.SteadyFor {
+			res.Reason = StopSteady
+			return res, nil
+		}
+		if history != nil {
+			gen, err := history.check(c)
+			if err != nil {
+				res.Reason = StopError
+				return res, err
+			}
+			if gen >= 0 {
+				res.Reason = StopRecurrence
+				res.Period = c.Generation - gen
+				return res, nil
+			}
+		}
+		if opts.Predicate != nil && opts.Predicate(c.CellsPerState) {
+			res.Reason = StopPredicate
+			return res, nil
+		}
+		if opts.Generations > 0 && res.Generations == opts.Generations {
+			res.Reason = StopGenerations
+			return res, nil
+		}
+
+		if err := c.Step(); err != nil {
+			res.Reason = StopError
+			return res, err
+		}
+		res.Generations++
+	}
+}
+
+// gridHash is the hash used to find grids that appeared before
+var gridHash = (*Grid).Hash
+
+// recurrenceHistory finds the previous generation with the same grid as
+// the automaton. Grids are looked up by their hash and confirmed running a
+// copy of the automaton from the first generation of the history, so hash
+// collisions are not taken as recurrences.
+type recurrenceHistory struct {
+	max         int              // Maximum number of generations kept
+	generations map[uint64][]int // Generations of each grid hash
+	start       *Cella2d         // Copy of the automaton at the first generation kept
+	len         int              // Number of generations kept
+}
+
+// check returns the previous generation with the same grid as the
+// automaton, -1 if there is none, and adds the generation to the history.
+// The history is cleared when it is full.
+func (r *recurrenceHistory) check(c *Cella2d) (int, error) {
+	h := gridHash(c.InitGrid)
+	if gens := r.generations[h]; len(gens) > 0 {
+		prev, err := r.confirm(c, gens)
+		if err != nil || prev >= 0 {
+			return prev, err
+		}
+	}
+	if r.start == nil || r.len >= r.max {
+		r.generations = make(map[uint64][]int)
+		r.start = c.Clone()
+		r.len = 0
+	}
+	r.generations[h] = append(r.generations[h], c.Generation)
+	r.len++
+	return -1, nil
+}
+
+// confirm runs a copy of the first generation of the history through the
+// generations, in ascending order, and returns the first one whose grid
+// is equal to the grid of the automaton, -1 if there is none
+func (r *recurrenceHistory) confirm(c *Cella2d, gens []int) (int, error) {
+	cp := r.start.Clone()
+	for _, gen := range gens {
+		for cp.Generation < gen {
+			if err := cp.Step(); err != nil {
+				return -1, err
+			}
+		}
+		if EqualsGrid(cp.InitGrid, c.InitGrid) {
+			return gen, nil
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,88 @@
+package cella
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunContext(t *testing.T) {
+	cells := map[string][][2]int{
+		"blinker": {{1, 2}, {2, 2}, {3, 2}},
+		"domino":  {{1, 1}, {2, 1}},
+		"block":   {{1, 1}, {2, 1}, {1, 2}, {2, 2}},
+	}
+	tests := []struct {
+		pattern     string
+		opts        RunOptions
+		reason      StopReason
+		generations int
+		period      int
+	}{
+		{"blinker", RunOptions{Generations: 5}, StopGenerations, 5, 0},
+		{"blinker", RunOptions{Generations: 5, StopOnRecurrence: true}, StopRecurrence, 2, 2},
+		{"domino", RunOptions{StopWhenEmpty: true}, StopEmpty, 1, 0},
+		{"block", RunOptions{SteadyFor: 3}, StopSteady, 3, 0},
+		{"blinker", RunOptions{Predicate: func(cps []int) bool { return cps[1] > 3 }, Generations: 5}, StopGenerations, 5, 0},
+		{"block", RunOptions{Predicate: func(cps []int) bool { return cps[1] == 4 }}, StopPredicate, 0, 0},
+	}
+	for _, test := range tests {
+		ca := newGameOfLife(5, 5)
+		for _, c := range cells[test.pattern] {
+			ca.InitGrid.SetCell(c[0], c[1], 1)
+		}
+		res, err := ca.RunContext(context.Background(), test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Reason != test.reason || res.Generations != test.generations ||
+			res.Generation != ca.Generation || res.Period != test.period {
+			t.Fatalf("Run of %s stopped by %s after %d generations with period %d",
+				test.pattern, res.Reason, res.Generations, res.Period)
+		}
+	}
+}
+
+func TestRunContextCancel(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := false
+	ca.AddObserver(&ObserverFuncs{
+		Step: func(c *Cella2d) {
+			if c.Generation == 3 {
+				cancel()
+			}
+		},
+		Stop: func(c *Cella2d) { stopped = true },
+	})
+	res, err := ca.RunContext(ctx, RunOptions{})
+	if err != context.Canceled || res.Reason != StopCancelled || res.Generations != 3 || !stopped {
+		t.Fatalf("Cancelled run returned %v: %s after %d generations", err, res.Reason, res.Generations)
+	}
+}
+
+func TestRunContextHashCollision(t *testing.T) {
+	// All the grids of a glider collide with a hash of the population
+	defer func(h func(*Grid) uint64) { gridHash = h }(gridHash)
+	gridHash = func(g *Grid) uint64 {
+		n := uint64(0)
+		for _, row := range g.Cells {
+			for _, s := range row {
+				n += uint64(s)
+			}
+		}
+		return n
+	}
+	ca := newGameOfLife(6, 6)
+	ca.SetBoundary(BoundaryToroidal)
+	for _, c := range [][2]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}} {
+		ca.InitGrid.SetCell(c[0], c[1], 1)
+	}
+	res, err := ca.RunContext(context.Background(), RunOptions{Generations: 30, StopOnRecurrence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The glider crosses the 6x6 torus in 24 generations
+	if res.Reason != StopRecurrence || res.Period != 24 || res.Generations != 24 {
+		t.Fatalf("Run stopped by %s after %d generations with period %d", res.Reason, res.Generations, res.Period)
+	}
+}
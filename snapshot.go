@@ -0,0 +1,259 @@
+package cella
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Snapshot format of a Cella2d.
+// A snapshot starts with a magic string and a version byte, followed by
+// a DEFLATE compressed payload of unsigned varints:
+//
+//	width, height, numStates, boundary, generation,
+//	len(states), states..., len(cellsPerState), cellsPerState...,
+//	len(rules), (state, len(condition), condition)...,
+//	hasGrid, wholeGrid cells (including the auxiliar borders)
+const (
+	snapshotMagic   = "CLA2"
+	snapshotVersion = 1
+)
+
+// maxSnapshotCells limits the size of the grid read from a snapshot
+const maxSnapshotCells = 1 << 30
+
+// MarshalBinary encodes the automaton into a versioned, compressed snapshot.
+// The initial grid (with its auxiliar borders), the rules, the boundary,
+// the generation and the number of cells per state are stored.
+// The next grid is not stored since it is recalculated on each generation.
+// Rules are stored by their condition, so only expression rules can be
+// stored: rules with a Go function, as the rules of Golly @TABLE and @TREE
+// files, make it fail. Such automata can be saved as patterns instead.
+func (c *Cella2d) MarshalBinary() ([]byte, error) {
+	var payload []byte
+	payload = binary.AppendUvarint(payload, uint64(c.Width))
+	payload = binary.AppendUvarint(payload, uint64(c.Height))
+	payload = binary.AppendUvarint(payload, uint64(c.NumStates))
+	payload = binary.AppendUvarint(payload, uint64(c.Boundary))
+	payload = binary.AppendUvarint(payload, uint64(c.Generation))
+
+	payload = binary.AppendUvarint(payload, uint64(len(c.States)))
+	for _, s := range c.States {
+		payload = binary.AppendUvarint(payload, uint64(s))
+	}
+	payload = binary.AppendUvarint(payload, uint64(len(c.CellsPerState)))
+	for _, n := range c.CellsPerState {
+		payload = binary.AppendUvarint(payload, uint64(n))
+	}
+
+	payload = binary.AppendUvarint(payload, uint64(len(c.Rules)))
+	for i, r := range c.Rules {
+		if r == nil {
+			return nil, fmt.Errorf("snapshot: rule %d is nil", i)
+		}
+		if !r.IsExpression() {
+			return nil, fmt.Errorf("snapshot: rule %d {%s} is not an expression", i, r.GetCondition())
+		}
+		payload = binary.AppendUvarint(payload, uint64(r.GetState()))
+		payload = binary.AppendUvarint(payload, uint64(len(r.GetCondition())))
+		payload = append(payload, r.GetCondition()...)
+	}
+
+	if c.InitGrid == nil {
+		payload = append(payload, 0)
+	} else {
+		if c.InitGrid.Width != c.Width || c.InitGrid.Height != c.Height {
+			return nil, fmt.Errorf("snapshot: initial grid is %dx%d, automaton is %dx%d",
+				c.InitGrid.Width, c.InitGrid.Height, c.Width, c.Height)
+		}
+		payload = append(payload, 1)
+		for _, row := range c.InitGrid.WholeGrid {
+			for _, cell := range row {
+				payload = append(payload, byte(cell))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	zw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a snapshot created by MarshalBinary into the automaton.
+// The initial and next grids are allocated again and the rules are rebuilt
+// from their conditions, so the automaton resumes with identical results.
+// The transitions of the last generation are cleared and the rule
+// statistics, if rules are tracked, start again for the new size and rules.
+func (c *Cella2d) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic)+1 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("snapshot: invalid header")
+	}
+	version := data[len(snapshotMagic)]
+	if version != snapshotVersion {
+		return fmt.Errorf("snapshot: unsupported version %d", version)
+	}
+	zr := flate.NewReader(bytes.NewReader(data[len(snapshotMagic)+1:]))
+	defer zr.Close()
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	r := bytes.NewReader(payload)
+
+	width, err := readSnapshotInt(r, "width")
+	if err != nil {
+		return err
+	}
+	height, err := readSnapshotInt(r, "height")
+	if err != nil {
+		return err
+	}
+	numStates, err := readSnapshotInt(r, "number of states")
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 || numStates < 2 || numStates > 256 {
+		return fmt.Errorf("snapshot: invalid dimensions %dx%d with %d states", width, height, numStates)
+	}
+	// Dividing avoids the overflow of the product of crafted sizes
+	if width > maxSnapshotCells || height > maxSnapshotCells || width+2 > maxSnapshotCells/(height+2) {
+		return fmt.Errorf("snapshot: grid %dx%d is too large", width, height)
+	}
+	boundary, err := readSnapshotInt(r, "boundary")
+	if err != nil {
+		return err
+	}
+	if b := Boundary(boundary); boundary > 255 || (b != BoundaryFixed && b != BoundaryToroidal) {
+		return fmt.Errorf("snapshot: unknown boundary %d", boundary)
+	}
+	generation, err := readSnapshotInt(r, "generation")
+	if err != nil {
+		return err
+	}
+
+	n, err := readSnapshotInt(r, "number of states")
+	if err != nil {
+		return err
+	}
+	if n > numStates {
+		return fmt.Errorf("snapshot: %d states stored, expected at most %d", n, numStates)
+	}
+	states := make([]Cell, numStates)
+	for i := 0; i < n; i++ {
+		s, err := readSnapshotInt(r, "state")
+		if err != nil {
+			return err
+		}
+		states[i] = Cell(s)
+	}
+	n, err = readSnapshotInt(r, "cells per state")
+	if err != nil {
+		return err
+	}
+	if n > numStates {
+		return fmt.Errorf("snapshot: %d cells per state stored, expected at most %d", n, numStates)
+	}
+	cellsPerState := make([]int, numStates)
+	for i := 0; i < n; i++ {
+		if cellsPerState[i], err = readSnapshotInt(r, "cells per state"); err != nil {
+			return err
+		}
+	}
+
+	n, err = readSnapshotInt(r, "number of rules")
+	if err != nil {
+		return err
+	}
+	if n > r.Len() {
+		return fmt.Errorf("snapshot: %d rules stored in %d bytes", n, r.Len())
+	}
+	rules := make([]*Rule2d, n)
+	for i := range rules {
+		state, err := readSnapshotInt(r, "rule state")
+		if err != nil {
+			return err
+		}
+		size, err := readSnapshotInt(r, "rule condition")
+		if err != nil {
+			return err
+		}
+		if size > r.Len() {
+			return fmt.Errorf("snapshot: rule %d condition is truncated", i)
+		}
+		condition := make([]byte, size)
+		if _, err := io.ReadFull(r, condition); err != nil {
+			return fmt.Errorf("snapshot: rule %d condition: %w", i, err)
+		}
+		if state >= numStates {
+			return fmt.Errorf("snapshot: rule %d state %d out of range [0, %d)", i, state, numStates)
+		}
+		rules[i] = NewRule2d(string(condition), Cell(state), numStates)
+	}
+
+	hasGrid, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("snapshot: grid flag: %w", err)
+	}
+	if hasGrid > 1 {
+		return fmt.Errorf("snapshot: invalid grid flag %d", hasGrid)
+	}
+	var initGrid, nextGrid *Grid
+	if hasGrid == 1 {
+		initGrid = NewGrid(width, height)
+		for _, row := range initGrid.WholeGrid {
+			for x := range row {
+				b, err := r.ReadByte()
+				if err != nil {
+					return fmt.Errorf("snapshot: grid is truncated")
+				}
+				row[x] = Cell(b)
+			}
+		}
+		nextGrid = NewGrid(width, height)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("snapshot: %d trailing bytes", r.Len())
+	}
+
+	c.Width = width
+	c.Height = height
+	c.NumStates = numStates
+	c.Boundary = Boundary(boundary)
+	c.Generation = generation
+	c.States = states
+	c.CellsPerState = cellsPerState
+	c.Rules = rules
+	c.InitGrid = initGrid
+	c.NextGrid = nextGrid
+	c.Transitions = newTransitions(numStates)
+	if c.ruleStats != nil {
+		c.ruleStats.Fired = newLayer(width, height)
+		c.ruleStats.resize(rules)
+	}
+	return nil
+}
+
+// readSnapshotInt reads a non negative integer stored as an unsigned varint
+func readSnapshotInt(r *bytes.Reader, name string) (int, error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot: reading %s: %w", name, err)
+	}
+	if v > uint64(^uint(0)>>1) {
+		return 0, fmt.Errorf("snapshot: %s out of range", name)
+	}
+	return int(v), nil
+}
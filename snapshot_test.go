@@ -0,0 +1,168 @@
+package cella
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// newGameOfLife creates a game of life automaton with empty grids
+func newGameOfLife(width, height int) *Cella2d {
+	numStates := 2
+	ca := NewCella2d(width, height, numStates)
+	ca.SetInitGrid(NewGrid(width, height))
+	ca.SetNextGrid(NewGrid(width, height))
+	r1 := NewRule2d("n11 == 1 && (s1 == 2 || s1 == 3)", 1, numStates)
+	r2 := NewRule2d("n11 == 0 && s1 == 3", 1, numStates)
+	r3 := NewRule2d("0==0", 0, numStates)
+	ca.SetRules([]*Rule2d{r1, r2, r3})
+	return ca
+}
+
+func TestSnapshotResume(t *testing.T) {
+	ca := newGameOfLife(8, 8)
+	ca.SetBoundary(BoundaryToroidal)
+	// Glider
+	ca.InitGrid.SetCell(1, 0, 1)
+	ca.InitGrid.SetCell(2, 1, 1)
+	ca.InitGrid.SetCell(0, 2, 1)
+	ca.InitGrid.SetCell(1, 2, 1)
+	ca.InitGrid.SetCell(2, 2, 1)
+	for i := 0; i < 5; i++ {
+		if err := ca.NextGeneration(); err != nil {
+			t.Fatal(err)
+		}
+		ca.InitGrid, ca.NextGrid = ca.NextGrid, ca.InitGrid
+	}
+	ca.CountCellsPerState()
+
+	data, err := ca.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := new(Cella2d)
+	if err := resumed.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Generation != 5 || resumed.Boundary != BoundaryToroidal || len(resumed.Rules) != 3 {
+		t.Fatalf("Resumed automaton does not match: %+v", resumed)
+	}
+	if resumed.CellsPerState[0] != 59 || resumed.CellsPerState[1] != 5 {
+		t.Fatalf("Resumed cells per state: %v", resumed.CellsPerState)
+	}
+	for i := 0; i < 20; i++ {
+		if err := ca.NextGeneration(); err != nil {
+			t.Fatal(err)
+		}
+		ca.InitGrid, ca.NextGrid = ca.NextGrid, ca.InitGrid
+		if err := resumed.NextGeneration(); err != nil {
+			t.Fatal(err)
+		}
+		resumed.InitGrid, resumed.NextGrid = resumed.NextGrid, resumed.InitGrid
+		if !EqualsGrid(ca.InitGrid, resumed.InitGrid) {
+			t.Fatalf("Resumed automaton differs on generation %d", ca.Generation)
+		}
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	ca := new(Cella2d)
+	if err := ca.UnmarshalBinary([]byte("CLA2")); err == nil {
+		t.Fatal("Truncated header should send an error")
+	}
+	if err := ca.UnmarshalBinary([]byte("CLA2\x09")); err == nil {
+		t.Fatal("Unknown version should send an error")
+	}
+	data, err := newGameOfLife(3, 3).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.UnmarshalBinary(data[:len(data)-3]); err == nil {
+		t.Fatal("Truncated payload should send an error")
+	}
+}
+
+// rawSnapshot creates a snapshot with the values of the payload encoded as
+// unsigned varints, followed by the bytes in tail
+func rawSnapshot(t *testing.T, values []uint64, tail ...byte) []byte {
+	var payload []byte
+	for _, v := range values {
+		payload = binary.AppendUvarint(payload, v)
+	}
+	payload = append(payload, tail...)
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	zw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(payload)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestSnapshotCrafted(t *testing.T) {
+	// width, height, states, boundary, generation, states, cells per state, rules
+	tests := []struct {
+		name   string
+		values []uint64
+		tail   []byte
+	}{
+		{"overflowing size", []uint64{1<<32 - 2, 1<<32 - 2, 2, 0, 0, 0, 0, 0}, []byte{0}},
+		{"huge width", []uint64{1 << 62, 1, 2, 0, 0, 0, 0, 0}, []byte{0}},
+		{"unknown boundary", []uint64{3, 3, 2, 7, 0, 0, 0, 0}, []byte{0}},
+		{"invalid grid flag", []uint64{3, 3, 2, 0, 0, 0, 0, 0}, []byte{2}},
+		{"rule state out of range", []uint64{3, 3, 2, 0, 0, 0, 0, 1, 2, 1}, []byte{'1', 0}},
+		{"rule state over 255", []uint64{3, 3, 256, 0, 0, 0, 0, 1, 257, 1}, []byte{'1', 0}},
+	}
+	ca := new(Cella2d)
+	for _, test := range tests {
+		if err := ca.UnmarshalBinary(rawSnapshot(t, test.values, test.tail...)); err == nil {
+			t.Fatalf("Snapshot with %s should send an error", test.name)
+		}
+	}
+	valid := rawSnapshot(t, []uint64{3, 3, 2, uint64(BoundaryToroidal), 0, 0, 0, 0}, 0)
+	if err := ca.UnmarshalBinary(valid); err != nil || ca.Boundary != BoundaryToroidal {
+		t.Fatalf("Snapshot without grid returned %v", err)
+	}
+}
+
+func TestSnapshotFunctionRules(t *testing.T) {
+	rule, err := LoadGollyRule(strings.NewReader("@RULE Test\n@TABLE\nn_states:2\nneighborhood:vonNeumann\nsymmetries:rotate4\n010001\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := newGameOfLife(3, 3)
+	ca.SetRules(rule.Rules())
+	if _, err := ca.MarshalBinary(); err == nil {
+		t.Fatal("Snapshot of Golly rules should send an error")
+	}
+}
+
+func TestSnapshotResize(t *testing.T) {
+	data, err := newGameOfLife(5, 4).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := newGameOfLife(3, 3)
+	stats := ca.TrackRules()
+	if err := ca.Step(); err != nil {
+		t.Fatal(err)
+	}
+	ca.Transitions[0][0] = 7
+	if err := ca.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if ca.Transitions[0][0] != 0 {
+		t.Fatal("Transitions were not cleared")
+	}
+	if err := ca.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Fired) != 4 || len(stats.Fired[0]) != 5 {
+		t.Fatalf("Fired is %dx%d after loading a 5x4 snapshot", len(stats.Fired[0]), len(stats.Fired))
+	}
+}
@@ -0,0 +1,429 @@
+package cella
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SoupOptions are the options of a random soup search
+type SoupOptions struct {
+	Rule      string           // Name of the rule, stored in the results
+	Rules     func() []*Rule2d // Creates the rules of each worker, e.g. Rulestring.Rules
+	NumStates int              // Number of states of the rules
+	Width     int              // Width of the soups, 16 if not set
+	Height    int              // Height of the soups, 16 if not set
+	Density   float64          // Probability of a cell not being in state 0, 0.5 if not set
+	Padding   int              // Empty cells around the soup where the debris can spread, 32 if not set
+	Seed      int64            // Seed of the first soup, soup i uses Seed+i
+	Soups     int              // Number of soups to search
+	Workers   int              // Number of soups run in parallel, the number of CPUs if not set
+	Samples   int              // Seeds kept for each object found, 10 if not set
+	Settle    AnalyzeOptions   // Options used to wait for a soup to stabilize
+	Census    CensusOptions    // Options used to classify the objects
+}
+
+// setDefaults sets the options not set to their default values
+func (o *SoupOptions) setDefaults() {
+	if o.Width <= 0 {
+		o.Width = 16
+	}
+	if o.Height <= 0 {
+		o.Height = 16
+	}
+	if o.Density <= 0 {
+		o.Density = 0.5
+	}
+	if o.Padding <= 0 {
+		o.Padding = 32
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.Samples <= 0 {
+		o.Samples = 10
+	}
+}
+
+// NewSoup creates the random soup of the given seed.
+// Cells not in state 0 take a random state from 1 to numStates-1.
+func NewSoup(width, height, numStates int, density float64, seed int64) *Grid {
+	g := NewGrid(width, height)
+	if g == nil {
+		return nil
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if rnd.Float64() < density {
+				g.Cells[y][x] = Cell(1 + rnd.Intn(numStates-1))
+			}
+		}
+	}
+	return g
+}
+
+// SoupResult are the results of a soup search
+type SoupResult struct {
+	Rule     string             `json:"rule"`
+	Width    int                `json:"width"`
+	Height   int                `json:"height"`
+	Density  float64            `json:"density"`
+	Soups    int                `json:"soups"`    // Soups searched
+	Unstable int                `json:"unstable"` // Soups that did not stabilize
+	Census   Census             `json:"census"`
+	Samples  map[string][]int64 `json:"samples"` // Seeds of soups where each object was found
+}
+
+// newSoupResult creates an empty result for the options
+func newSoupResult(opts *SoupOptions) *SoupResult {
+	return &SoupResult{
+		Rule:    opts.Rule,
+		Width:   opts.Width,
+		Height:  opts.Height,
+		Density: opts.Density,
+		Census:  make(Census),
+		Samples: make(map[string][]int64),
+	}
+}
+
+// addSample adds the seed of a soup where the object was found,
+// keeping the smallest seeds
+func (r *SoupResult) addSample(code string, seed int64, max int) {
+	s := r.Samples[code]
+	if len(s) >= max && seed >= s[len(s)-1] {
+		return
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i] >= seed })
+	if i < len(s) && s[i] == seed {
+		return
+	}
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = seed
+	if len(s) > max {
+		s = s[:max]
+	}
+	r.Samples[code] = s
+}
+
+// Merge adds the results of another search of the same rule and soups.
+// At most max samples are kept for each object, all of them if max is 0.
+func (r *SoupResult) Merge(other *SoupResult, max int) error {
+	if r.Rule != other.Rule || r.Width != other.Width || r.Height != other.Height || r.Density != other.Density {
+		return fmt.Errorf("soup: cannot merge results of %s %dx%d with density %g into %s %dx%d with density %g",
+			other.Rule, other.Width, other.Height, other.Density, r.Rule, r.Width, r.Height, r.Density)
+	}
+	r.Soups += other.Soups
+	r.Unstable += other.Unstable
+	if r.Census == nil {
+		r.Census = make(Census)
+	}
+	r.Census.Merge(other.Census)
+	if r.Samples == nil {
+		r.Samples = make(map[string][]int64)
+	}
+	for code, seeds := range other.Samples {
+		limit := max
+		if limit <= 0 {
+			limit = len(seeds) + len(r.Samples[code])
+		}
+		for _, seed := range seeds {
+			r.addSample(code, seed, limit)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the results as JSON
+func (r *SoupResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ReadSoupResult reads results written by WriteJSON
+func ReadSoupResult(r io.Reader) (*SoupResult, error) {
+	res := new(SoupResult)
+	if err := json.NewDecoder(r).Decode(res); err != nil {
+		return nil, fmt.Errorf("soup: %w", err)
+	}
+	if res.Census == nil {
+		res.Census = make(Census)
+	}
+	if res.Samples == nil {
+		res.Samples = make(map[string][]int64)
+	}
+	return res, nil
+}
+
+// soupWorker runs soups with its own automaton and rules
+type soupWorker struct {
+	opts       *SoupOptions
+	automaton  *Cella2d
+	classifier *Classifier
+	escapes    *escapeRemover
+	result     *SoupResult
+}
+
+// Limits of the objects near the edges of the grid that are checked for
+// spaceships
+const (
+	maxEscapingPopulation = 64 // Maximum number of cells
+	maxEscapingPeriod     = 16 // Maximum period
+)
+
+// escapeRemover is an observer that removes the spaceships about to reach
+// the edges of the grid and counts them in a census. As in apgsearch,
+// spaceships escaping from the soup are counted before they crash into the
+// fixed boundary of the grid, which lets the rest of the soup settle.
+type escapeRemover struct {
+	classifier *Classifier
+	margin     int              // Distance to the edges where objects are checked
+	every      int              // Generations between checks
+	census     Census           // Spaceships removed
+	err        error            // First error classifying an object
+	seen       map[uint64]Point // Last position of the objects near the edges by hash
+	movers     map[uint64]bool  // Objects known to move or not by hash
+}
+
+// newEscapeRemover creates a remover for soups surrounded by the padding
+func newEscapeRemover(cl *Classifier, padding int) *escapeRemover {
+	margin := padding / 2
+	if margin > 16 {
+		margin = 16
+	}
+	if margin < 4 {
+		margin = 4
+	}
+	// Spaceships moving at c/2 cross a quarter of the margin between checks
+	return &escapeRemover{classifier: cl, margin: margin, every: margin / 2, census: make(Census),
+		seen: make(map[uint64]Point), movers: make(map[uint64]bool)}
+}
+
+// reset empties the census of the spaceships removed and forgets the
+// objects seen
+func (e *escapeRemover) reset() {
+	e.census = make(Census)
+	e.seen = make(map[uint64]Point)
+	e.err = nil
+}
+
+// OnStep removes the spaceships near the edges of the grid
+func (e *escapeRemover) OnStep(c *Cella2d) {
+	if e.err != nil || c.Generation%e.every != 0 {
+		return
+	}
+	g := c.InitGrid
+	x, y, w, h, ok := g.BoundingBox()
+	if !ok || (x >= e.margin && y >= e.margin && x+w <= g.Width-e.margin && y+h <= g.Height-e.margin) {
+		return
+	}
+	for _, o := range g.Components(e.classifier.Options.Connectivity) {
+		if o.Population > maxEscapingPopulation || (o.X >= e.margin && o.Y >= e.margin &&
+			o.X+o.Grid.Width <= g.Width-e.margin && o.Y+o.Grid.Height <= g.Height-e.margin) {
+			continue
+		}
+		// Only objects seen before in another position can be spaceships,
+		// which saves running the debris of the soup
+		h := o.Grid.Hash()
+		p, ok := e.seen[h]
+		e.seen[h] = Point{o.X, o.Y}
+		if !ok || p == e.seen[h] {
+			continue
+		}
+		moves, err := e.moves(c, h, o.Grid)
+		if err != nil {
+			e.err = err
+			return
+		}
+		if !moves {
+			continue
+		}
+		code, err := e.classifier.Classify(o.Grid)
+		if err != nil {
+			e.err = err
+			return
+		}
+		if !strings.HasPrefix(code, "xq") {
+			continue
+		}
+		e.census[code]++
+		for oy, row := range o.Grid.Cells {
+			for ox, s := range row {
+				if s != 0 {
+					g.Cells[o.Y+oy][o.X+ox] = 0
+					c.CellsPerState[s]--
+					c.CellsPerState[0]++
+				}
+			}
+		}
+	}
+}
+
+// moves runs the object alone for up to maxEscapingPeriod generations and
+// reports if it appears again in another position. It is a quick check
+// before the object is classified, since most objects near the edges are
+// debris that do not move.
+func (e *escapeRemover) moves(c *Cella2d, h uint64, g *Grid) (bool, error) {
+	if m, ok := e.movers[h]; ok {
+		return m, nil
+	}
+	// Spaceships move at most one cell every two generations
+	pad := maxEscapingPeriod/2 + 2
+	a := NewCella2d(g.Width+2*pad, g.Height+2*pad, c.NumStates)
+	a.SetRules(c.Rules)
+	a.SetInitGrid(NewGrid(a.Width, a.Height))
+	a.SetNextGrid(NewGrid(a.Width, a.Height))
+	a.InitGrid.Paste(g, pad, pad, PasteCopy)
+	moves := false
+	for gen := 0; gen < maxEscapingPeriod; gen++ {
+		if err := a.Step(); err != nil {
+			return false, err
+		}
+		x, y, _, _, ok := a.InitGrid.BoundingBox()
+		if !ok {
+			break
+		}
+		if (x != pad || y != pad) && EqualsGrid(a.InitGrid.Trim(), g) {
+			moves = true
+			break
+		}
+	}
+	e.movers[h] = moves
+	return moves, nil
+}
+
+// OnRuleError does nothing, errors are returned by Analyze
+func (e *escapeRemover) OnRuleError(c *Cella2d, err error) {}
+
+// OnStop does nothing
+func (e *escapeRemover) OnStop(c *Cella2d) {}
+
+// newSoupWorker creates a worker for the options
+func newSoupWorker(opts *SoupOptions) *soupWorker {
+	w := opts.Width + 2*opts.Padding
+	h := opts.Height + 2*opts.Padding
+	c := NewCella2d(w, h, opts.NumStates)
+	c.SetRules(opts.Rules())
+	c.SetInitGrid(NewGrid(w, h))
+	c.SetNextGrid(NewGrid(w, h))
+	cl := NewClassifier(c, opts.Census)
+	escapes := newEscapeRemover(cl, opts.Padding)
+	c.AddObserver(escapes)
+	return &soupWorker{
+		opts:       opts,
+		automaton:  c,
+		classifier: cl,
+		escapes:    escapes,
+		result:     newSoupResult(opts),
+	}
+}
+
+// run runs the soup of the given seed until it stabilizes and adds its
+// objects to the census
+func (w *soupWorker) run(seed int64) error {
+	opts := w.opts
+	return w.runSoup(NewSoup(opts.Width, opts.Height, opts.NumStates, opts.Density, seed), seed)
+}
+
+// runSoup runs the soup until it stabilizes and adds its objects, and the
+// spaceships that escaped from it, to the census with the given seed
+func (w *soupWorker) runSoup(soup *Grid, seed int64) error {
+	opts := w.opts
+	c := w.automaton
+	c.InitGrid.Fill(0)
+	c.InitGrid.Paste(soup, opts.Padding, opts.Padding, PasteCopy)
+	c.SetGeneration(0)
+	c.CountCellsPerState()
+	w.escapes.reset()
+
+	w.result.Soups++
+	res, err := Analyze(c, opts.Settle)
+	if err != nil {
+		return err
+	}
+	if w.escapes.err != nil {
+		return w.escapes.err
+	}
+	cs := w.escapes.census
+	if res.Kind == KindUnknown {
+		w.result.Unstable++
+		w.result.addSample(unknownCode, seed, opts.Samples)
+	} else if err := w.classifier.Add(cs, c.InitGrid); err != nil {
+		return err
+	}
+	w.result.Census.Merge(cs)
+	for code := range cs {
+		w.result.addSample(code, seed, opts.Samples)
+	}
+	return nil
+}
+
+// SearchSoups runs random soups until they stabilize, separates them into
+// objects and takes a census of the objects found. Soups run in parallel,
+// each worker with its own rules, and the results do not depend on the
+// number of workers. The search stops early if the context is cancelled,
+// returning the results of the soups completed and the context error.
+func SearchSoups(ctx context.Context, opts SoupOptions) (*SoupResult, error) {
+	if opts.Rules == nil {
+		return nil, fmt.Errorf("soup: no rules")
+	}
+	if opts.NumStates < 2 {
+		return nil, fmt.Errorf("soup: invalid number of states %d", opts.NumStates)
+	}
+	opts.setDefaults()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	seeds := make(chan int64)
+	go func() {
+		defer close(seeds)
+		for i := 0; i < opts.Soups && ctx.Err() == nil; i++ {
+			select {
+			case seeds <- opts.Seed + int64(i):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	workers := make([]*soupWorker, opts.Workers)
+	errs := make([]error, opts.Workers)
+	for i := range workers {
+		workers[i] = newSoupWorker(&opts)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for seed := range seeds {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := workers[i].run(seed); err != nil {
+					errs[i] = fmt.Errorf("soup: seed %d: %w", seed, err)
+					cancel()
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	res := newSoupResult(&opts)
+	for _, w := range workers {
+		res.Merge(w.result, opts.Samples)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, ctx.Err()
+}
@@ -0,0 +1,100 @@
+package cella
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSearchSoups(t *testing.T) {
+	rs, err := ParseRulestring("B3/S23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := SoupOptions{
+		Rule:      rs.String(),
+		Rules:     rs.Rules,
+		NumStates: rs.NumStates,
+		Width:     6,
+		Height:    6,
+		Padding:   8,
+		Seed:      1,
+		Soups:     6,
+		Settle:    AnalyzeOptions{MaxGenerations: 200},
+		Census:    CensusOptions{Padding: 6, Analyze: AnalyzeOptions{MaxGenerations: 50}},
+	}
+	opts.Workers = 1
+	serial, err := SearchSoups(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Workers = 3
+	parallel, err := SearchSoups(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial.Soups != 6 || !reflect.DeepEqual(serial, parallel) {
+		t.Fatalf("Results depend on the number of workers:\n%v\n%v", serial, parallel)
+	}
+
+	var buf bytes.Buffer
+	if err := serial.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSoupResult(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := read.Merge(parallel, 0); err != nil {
+		t.Fatal(err)
+	}
+	if read.Soups != 12 || read.Census.Total() != 2*serial.Census.Total() {
+		t.Fatalf("Merged results do not match: %+v", read)
+	}
+	other := *serial
+	other.Rule = "B36/S23"
+	if err := read.Merge(&other, 0); err == nil {
+		t.Fatal("Results of different rules were merged")
+	}
+}
+
+func TestSearchSoupsCancel(t *testing.T) {
+	rs, _ := ParseRulestring("B3/S23")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := SearchSoups(ctx, SoupOptions{Rules: rs.Rules, NumStates: 2, Width: 4, Height: 4, Padding: 4, Soups: 1000})
+	if err != context.Canceled || res.Soups >= 1000 {
+		t.Fatalf("Cancelled search returned %v after %d soups", err, res.Soups)
+	}
+}
+
+func TestSoupEscapingGlider(t *testing.T) {
+	rs, err := ParseRulestring("B3/S23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := SoupOptions{Rules: rs.Rules, NumStates: rs.NumStates, Width: 8, Height: 8, Padding: 12}
+	opts.setDefaults()
+	w := newSoupWorker(&opts)
+	// A block and a glider moving away from it, to the bottom right corner
+	soup := gridFromRows(
+		"11000000",
+		"11000000",
+		"00000000",
+		"00000000",
+		"00000100",
+		"00000010",
+		"00001110",
+	)
+	if err := w.runSoup(soup, 7); err != nil {
+		t.Fatal(err)
+	}
+	cs := w.result.Census
+	if cs["xq4_153"] != 1 || cs["xs4_33"] != 1 || cs.Total() != 2 || w.result.Unstable != 0 {
+		t.Fatalf("Census of a soup with a glider does not match:\n%s", cs)
+	}
+	if seeds := w.result.Samples["xq4_153"]; len(seeds) != 1 || seeds[0] != 7 {
+		t.Fatalf("Glider samples do not match: %v", seeds)
+	}
+}
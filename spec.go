@@ -0,0 +1,381 @@
+package cella
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a declarative definition of a cellular automaton 2D
+// that can be loaded from JSON or YAML
+type Spec struct {
+	Width         int          `json:"width" yaml:"width"`                                     // Width of the grid
+	Height        int          `json:"height" yaml:"height"`                                   // Height of the grid
+	NumStates     int          `json:"numStates,omitempty" yaml:"numStates,omitempty"`         // Number of states, optional if states are listed
+	States        []StateSpec  `json:"states,omitempty" yaml:"states,omitempty"`               // Names and colors of the states
+	Rulestring    string       `json:"rulestring,omitempty" yaml:"rulestring,omitempty"`       // Rule in B/S notation
+	Rules         []RuleSpec   `json:"rules,omitempty" yaml:"rules,omitempty"`                 // Rules as conditions, used instead of a rulestring
+	Neighbourhood string       `json:"neighbourhood,omitempty" yaml:"neighbourhood,omitempty"` // "moore" or "vonneumann", only used with a rulestring
+	Boundary      string       `json:"boundary,omitempty" yaml:"boundary,omitempty"`           // "fixed" or "toroidal"
+	Pattern       *PatternSpec `json:"pattern,omitempty" yaml:"pattern,omitempty"`             // Initial pattern
+}
+
+// StateSpec is the name and color of a state
+type StateSpec struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`   // Name of the state, unique
+	Color string `json:"color,omitempty" yaml:"color,omitempty"` // Color of the state as #rrggbb
+}
+
+// RuleSpec is a rule given as a condition and the state to change to
+type RuleSpec struct {
+	Condition string `json:"condition" yaml:"condition"` // Condition to change state
+	State     int    `json:"state" yaml:"state"`         // State to change to
+}
+
+// PatternSpec is an initial pattern placed in the grid at X, Y.
+// Each row is a string where each character is the state of a cell:
+// '.' is 0, '*' is 1, '0'-'9' are 0-9 and 'A'-'Z' are 10-35.
+// The pattern can also be given in RLE format instead of rows.
+type PatternSpec struct {
+	X    int      `json:"x,omitempty" yaml:"x,omitempty"`       // Column of the top left corner
+	Y    int      `json:"y,omitempty" yaml:"y,omitempty"`       // Row of the top left corner
+	Rows []string `json:"rows,omitempty" yaml:"rows,omitempty"` // Rows of the pattern
+	RLE  string   `json:"rle,omitempty" yaml:"rle,omitempty"`   // Pattern in RLE format
+}
+
+// SpecError is an error in a Spec pointing to the offending field
+type SpecError struct {
+	Field string // Path of the field, e.g. "rules[2].condition"
+	Err   error  // Error found in the field
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("spec: %s: %v", e.Field, e.Err)
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// specErrorf creates a SpecError for a field
+func specErrorf(field, format string, a ...interface{}) error {
+	return &SpecError{Field: field, Err: fmt.Errorf(format, a...)}
+}
+
+// LoadSpecJSON reads a Spec in JSON format. Unknown fields are rejected
+func LoadSpecJSON(r io.Reader) (*Spec, error) {
+	s := new(Spec)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+	return s, nil
+}
+
+// LoadSpecYAML reads a Spec in YAML format. Unknown fields are rejected
+func LoadSpecYAML(r io.Reader) (*Spec, error) {
+	s := new(Spec)
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+	return s, nil
+}
+
+// LoadSpecFile reads a Spec from a file. Files with a .json extension
+// are read as JSON and any other file as YAML
+func LoadSpecFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return LoadSpecJSON(bytes.NewReader(data))
+	}
+	return LoadSpecYAML(bytes.NewReader(data))
+}
+
+// numStates returns the number of states defined by the spec
+func (s *Spec) numStates() (int, error) {
+	n := s.NumStates
+	if len(s.States) > 0 {
+		if n != 0 && n != len(s.States) {
+			return 0, specErrorf("numStates", "%d states declared but %d listed in states", n, len(s.States))
+		}
+		n = len(s.States)
+	}
+	if s.Rulestring != "" {
+		rs, err := ParseRulestring(s.Rulestring)
+		if err != nil {
+			return 0, &SpecError{Field: "rulestring", Err: err}
+		}
+		if n != 0 && n != rs.NumStates {
+			return 0, specErrorf("rulestring", "rule has %d states but the spec declares %d", rs.NumStates, n)
+		}
+		n = rs.NumStates
+	}
+	if n < 2 || n > 256 {
+		return 0, specErrorf("numStates", "number of states must be between 2 and 256, got %d", n)
+	}
+	return n, nil
+}
+
+// Build creates a ready to run Cella2d from the spec
+func (s *Spec) Build() (*Cella2d, error) {
+	if s.Width <= 0 {
+		return nil, specErrorf("width", "must be positive, got %d", s.Width)
+	}
+	if s.Height <= 0 {
+		return nil, specErrorf("height", "must be positive, got %d", s.Height)
+	}
+	numStates, err := s.numStates()
+	if err != nil {
+		return nil, err
+	}
+	for i, st := range s.States {
+		if st.Color == "" {
+			continue
+		}
+		if _, err := parseColor(st.Color); err != nil {
+			return nil, &SpecError{Field: fmt.Sprintf("states[%d].color", i), Err: err}
+		}
+	}
+	if _, err := s.StateNames(); err != nil {
+		return nil, err
+	}
+	boundary, err := parseBoundary(s.Boundary)
+	if err != nil {
+		return nil, &SpecError{Field: "boundary", Err: err}
+	}
+	neighbourhood, err := ParseNeighbourhood(s.Neighbourhood)
+	if err != nil {
+		return nil, &SpecError{Field: "neighbourhood", Err: err}
+	}
+
+	var rules []*Rule2d
+	switch {
+	case s.Rulestring != "" && len(s.Rules) > 0:
+		return nil, specErrorf("rules", "rules and rulestring can not be used together")
+	case s.Rulestring != "":
+		rs, _ := ParseRulestring(s.Rulestring)
+		if s.Neighbourhood != "" {
+			if rs.Neighbourhood != NeighbourhoodMoore && rs.Neighbourhood != neighbourhood {
+				return nil, specErrorf("neighbourhood", "rulestring uses the %s neighbourhood", rs.Neighbourhood)
+			}
+			rs.Neighbourhood = neighbourhood
+			if err := rs.check(); err != nil {
+				return nil, &SpecError{Field: "neighbourhood", Err: err}
+			}
+		}
+		rules = rs.Rules()
+	case len(s.Rules) > 0 && s.Neighbourhood != "":
+		return nil, specErrorf("neighbourhood", "only used with a rulestring, not with rules")
+	case len(s.Rules) > 0:
+		rules, err = s.buildRules(numStates)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, specErrorf("rules", "either rules or rulestring must be set")
+	}
+
+	c := NewCella2d(s.Width, s.Height, numStates)
+	c.SetInitGrid(NewGrid(s.Width, s.Height))
+	c.SetNextGrid(NewGrid(s.Width, s.Height))
+	c.SetRules(rules)
+	c.SetBoundary(boundary)
+	if s.Pattern != nil {
+		if err := s.Pattern.place(c.InitGrid, numStates); err != nil {
+			return nil, err
+		}
+	}
+	c.CountCellsPerState()
+	return c, nil
+}
+
+// StateNames returns the name of each state, empty for states without a
+// name. Names must be unique.
+func (s *Spec) StateNames() ([]string, error) {
+	numStates, err := s.numStates()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, numStates)
+	seen := make(map[string]int)
+	for i, st := range s.States {
+		if st.Name == "" {
+			continue
+		}
+		if prev, ok := seen[st.Name]; ok {
+			return nil, specErrorf(fmt.Sprintf("states[%d].name", i), "name %q already used by state %d", st.Name, prev)
+		}
+		seen[st.Name] = i
+		names[i] = st.Name
+	}
+	return names, nil
+}
+
+// State returns the state with the given name, the second value is false
+// if no state has the name
+func (s *Spec) State(name string) (Cell, bool) {
+	for i, st := range s.States {
+		if st.Name != "" && st.Name == name {
+			return Cell(i), true
+		}
+	}
+	return 0, false
+}
+
+// Palette returns the colors of the states of the spec.
+// States without color use the default palette.
+func (s *Spec) Palette() (Palette, error) {
+	numStates, err := s.numStates()
+	if err != nil {
+		return nil, err
+	}
+	p := DefaultPalette(numStates)
+	for i, st := range s.States {
+		if st.Color == "" {
+			continue
+		}
+		c, err := parseColor(st.Color)
+		if err != nil {
+			return nil, &SpecError{Field: fmt.Sprintf("states[%d].color", i), Err: err}
+		}
+		p[i] = c
+	}
+	return p, nil
+}
+
+// buildRules creates the rules from their conditions checking that each
+// condition can be evaluated and returns a boolean
+func (s *Spec) buildRules(numStates int) ([]*Rule2d, error) {
+	neighbours := make([][]Cell, 3)
+	for i := range neighbours {
+		neighbours[i] = make([]Cell, 3)
+	}
+	rules := make([]*Rule2d, len(s.Rules))
+	for i, rs := range s.Rules {
+		if rs.State < 0 || rs.State >= numStates {
+			return nil, specErrorf(fmt.Sprintf("rules[%d].state", i), "state %d out of range [0, %d)", rs.State, numStates)
+		}
+		if strings.TrimSpace(rs.Condition) == "" {
+			return nil, specErrorf(fmt.Sprintf("rules[%d].condition", i), "empty condition")
+		}
+		r := NewRule2d(rs.Condition, Cell(rs.State), numStates)
+		r.SetNeighbourhood(neighbours)
+		if _, err := r.CheckCondition(); err != nil {
+			return nil, &SpecError{Field: fmt.Sprintf("rules[%d].condition", i), Err: err}
+		}
+		rules[i] = r
+	}
+	return rules, nil
+}
+
+// place sets the cells of the pattern in the grid
+func (p *PatternSpec) place(g *Grid, numStates int) error {
+	if p.X < 0 || p.Y < 0 {
+		return specErrorf("pattern", "position (%d, %d) is negative", p.X, p.Y)
+	}
+	if p.RLE != "" {
+		return p.placeRLE(g, numStates)
+	}
+	if p.Y+len(p.Rows) > g.Height {
+		return specErrorf("pattern.rows", "%d rows at y=%d do not fit in height %d", len(p.Rows), p.Y, g.Height)
+	}
+	for y, row := range p.Rows {
+		if p.X+len(row) > g.Width {
+			return specErrorf(fmt.Sprintf("pattern.rows[%d]", y), "%d cells at x=%d do not fit in width %d", len(row), p.X, g.Width)
+		}
+		for x, ch := range []byte(row) {
+			state, ok := patternCharState(ch)
+			if !ok || state >= numStates {
+				return specErrorf(fmt.Sprintf("pattern.rows[%d]", y), "invalid cell %q at column %d", ch, x)
+			}
+			g.SetCell(p.X+x, p.Y+y, Cell(state))
+		}
+	}
+	return nil
+}
+
+// placeRLE sets the cells of the RLE pattern in the grid
+func (p *PatternSpec) placeRLE(g *Grid, numStates int) error {
+	if len(p.Rows) > 0 {
+		return specErrorf("pattern.rle", "rle and rows can not be used together")
+	}
+	pg, _, err := ReadRLE(strings.NewReader(p.RLE))
+	if err != nil {
+		return &SpecError{Field: "pattern.rle", Err: err}
+	}
+	if p.X+pg.Width > g.Width || p.Y+pg.Height > g.Height {
+		return specErrorf("pattern.rle", "%dx%d pattern at (%d, %d) does not fit in the %dx%d grid",
+			pg.Width, pg.Height, p.X, p.Y, g.Width, g.Height)
+	}
+	for y := 0; y < pg.Height; y++ {
+		for x := 0; x < pg.Width; x++ {
+			state := pg.GetCell(x, y)
+			if int(state) >= numStates {
+				return specErrorf("pattern.rle", "state %d at (%d, %d) out of range [0, %d)", state, x, y, numStates)
+			}
+			g.SetCell(p.X+x, p.Y+y, state)
+		}
+	}
+	return nil
+}
+
+// patternCharState returns the state of a character of a PatternSpec row
+func patternCharState(ch byte) (int, bool) {
+	switch {
+	case ch == '.':
+		return 0, true
+	case ch == '*':
+		return 1, true
+	case ch >= '0' && ch <= '9':
+		return int(ch - '0'), true
+	case ch >= 'A' && ch <= 'Z':
+		return int(ch-'A') + 10, true
+	}
+	return 0, false
+}
+
+// parseBoundary parses a boundary name ("fixed" or "toroidal")
+func parseBoundary(name string) (Boundary, error) {
+	switch strings.ToLower(name) {
+	case "", "fixed":
+		return BoundaryFixed, nil
+	case "toroidal", "torus":
+		return BoundaryToroidal, nil
+	}
+	return 0, fmt.Errorf("unknown boundary %q", name)
+}
+
+// parseColor parses a color written as #rrggbb or #rgb
+func parseColor(s string) (color.RGBA, error) {
+	c := color.RGBA{A: 0xff}
+	hex := strings.TrimPrefix(s, "#")
+	var err error
+	switch len(hex) {
+	case 6:
+		_, err = fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 3:
+		_, err = fmt.Sscanf(hex, "%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R *= 0x11
+		c.G *= 0x11
+		c.B *= 0x11
+	default:
+		err = fmt.Errorf("expected #rrggbb")
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	return c, nil
+}
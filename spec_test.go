@@ -0,0 +1,103 @@
+package cella
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRulestring(t *testing.T) {
+	tests := []struct {
+		rule      string
+		canonical string
+	}{
+		{"B3/S23", "B3/S23"},
+		{"b36s23", "B36/S23"},
+		{"B9/S23", ""},
+		{"23/3", "B3/S23"},
+		{"B2/S/C3", "B2/S/C3"},
+		{"/2/3", "B2/S/C3"},
+		{"B1/S1V", "B1/S1V"},
+	}
+	for _, test := range tests {
+		rs, err := ParseRulestring(test.rule)
+		if test.canonical == "" {
+			if err == nil {
+				t.Fatalf("Rulestring %s should send an error", test.rule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rs.String() != test.canonical {
+			t.Fatalf("Rulestring %s parsed as %s", test.rule, rs.String())
+		}
+	}
+}
+
+func TestSpecJSON(t *testing.T) {
+	spec, err := LoadSpecJSON(strings.NewReader(`{
+		"width": 5, "height": 5,
+		"states": [{"name": "dead", "color": "#000"}, {"name": "alive", "color": "#ffffff"}],
+		"rulestring": "B3/S23",
+		"boundary": "toroidal",
+		"pattern": {"x": 1, "y": 2, "rows": ["***"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.CellsPerState[1] != 3 || ca.Boundary != BoundaryToroidal {
+		t.Fatalf("Automaton built from spec does not match: %v", ca.CellsPerState)
+	}
+	if names, err := spec.StateNames(); err != nil || names[0] != "dead" || names[1] != "alive" {
+		t.Fatalf("State names do not match: %v, %v", names, err)
+	}
+	if st, ok := spec.State("alive"); !ok || st != 1 {
+		t.Fatal("State alive not found")
+	}
+	if _, ok := spec.State("zombie"); ok {
+		t.Fatal("Unknown state found")
+	}
+	if err := ca.NextGeneration(); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGrid(5, 5)
+	g.SetCell(2, 1, 1)
+	g.SetCell(2, 2, 1)
+	g.SetCell(2, 3, 1)
+	if !EqualsGrid(ca.NextGrid, g) {
+		t.Fatal("Blinker built from spec does not oscillate")
+	}
+}
+
+func TestSpecYAMLErrors(t *testing.T) {
+	tests := []struct {
+		yaml  string
+		field string
+	}{
+		{"width: 4\nheight: 4\nnumStates: 2\nrules:\n  - condition: n11 == 1\n    state: 2\n", "rules[0].state"},
+		{"width: 4\nheight: 4\nnumStates: 2\nrules:\n  - condition: n11 +\n    state: 1\n", "rules[0].condition"},
+		{"width: 4\nheight: 4\nrulestring: B3/S23\nboundary: sphere\n", "boundary"},
+		{"width: 4\nheight: 4\nrulestring: B3/S23\npattern:\n  rows: ['..', '.x']\n", "pattern.rows[1]"},
+		{"width: 4\nheight: 4\nrulestring: B3/S23\nstates: [{name: a}, {name: b, color: red}]\n", "states[1].color"},
+		{"width: 4\nheight: 4\nrulestring: B3/S23\nstates: [{name: a}, {name: a}]\n", "states[1].name"},
+		{"width: 4\nheight: 4\nrulestring: B5/S23\nneighbourhood: vonneumann\n", "neighbourhood"},
+		{"width: 4\nheight: 4\nnumStates: 2\nneighbourhood: vonneumann\nrules:\n  - condition: n11 == 1\n    state: 1\n", "neighbourhood"},
+	}
+	for _, test := range tests {
+		spec, err := LoadSpecYAML(strings.NewReader(test.yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = spec.Build()
+		var specErr *SpecError
+		if !errors.As(err, &specErr) || specErr.Field != test.field {
+			t.Fatalf("Expected error on field %s, got %v", test.field, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package cella
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// ColorMode is the color support of a terminal
+type ColorMode uint8
+
+const (
+	ColorMode256       ColorMode = iota // 256 color palette
+	ColorModeTrueColor                  // 24 bit colors
+)
+
+// TermOptions are the options to render a grid in a terminal.
+// Each character shows two cells, one on top of the other, using half blocks.
+type TermOptions struct {
+	Palette   Palette   // Colors of the states, the default palette is used if nil
+	ColorMode ColorMode // Color support of the terminal
+	X, Y      int       // Cell shown in the top left corner of the viewport
+	Columns   int       // Width of the viewport in characters, the whole grid if 0
+	Rows      int       // Height of the viewport in characters, the whole grid if 0
+}
+
+// viewportSize returns the size of the viewport in characters
+func (o *TermOptions) viewportSize(g *Grid) (int, int) {
+	cols, rows := o.Columns, o.Rows
+	if cols <= 0 {
+		cols = g.Width - o.X
+	}
+	if rows <= 0 {
+		rows = (g.Height - o.Y + 1) / 2
+	}
+	return cols, rows
+}
+
+// ansiColor returns the escape sequence that sets a foreground or background color
+func ansiColor(c color.Color, mode ColorMode, background bool) string {
+	r, g, b, _ := c.RGBA()
+	r, g, b = r>>8, g>>8, b>>8
+	layer := 38
+	if background {
+		layer = 48
+	}
+	if mode == ColorModeTrueColor {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, r, g, b)
+	}
+	return fmt.Sprintf("\x1b[%d;5;%dm", layer, xterm256(r, g, b))
+}
+
+// xterm256 returns the closest color of the xterm 256 color palette,
+// using the 6x6x6 color cube or the gray ramp
+func xterm256(r, g, b uint32) int {
+	cube := func(v uint32) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return int((v - 35) / 40)
+	}
+	levels := []uint32{0, 95, 135, 175, 215, 255}
+	cr, cg, cb := cube(r), cube(g), cube(b)
+	dist := func(x, y, z uint32) uint32 {
+		d := func(a, b uint32) uint32 {
+			if a > b {
+				return (a - b) * (a - b)
+			}
+			return (b - a) * (b - a)
+		}
+		return d(x, r) + d(y, g) + d(z, b)
+	}
+	cubeIdx := 16 + 36*cr + 6*cg + cb
+	cubeDist := dist(levels[cr], levels[cg], levels[cb])
+
+	avg := (r + g + b) / 3
+	grayIdx := 23
+	if avg < 238 {
+		grayIdx = 0
+		if avg > 8 {
+			grayIdx = int((avg - 8) / 10)
+		}
+	}
+	gray := uint32(8 + 10*grayIdx)
+	if dist(gray, gray, gray) < cubeDist {
+		return 232 + grayIdx
+	}
+	return cubeIdx
+}
+
+// WriteANSI writes the viewport of the grid to a terminal using half block
+// characters and ANSI colors. Lines end with "\r\n" so the output also works
+// in raw mode. Cells outside of the grid are left with the default colors.
+func WriteANSI(w io.Writer, g *Grid, opts TermOptions) error {
+	return writeANSI(w, g, &opts, -1, -1)
+}
+
+// writeANSI writes the viewport of the grid highlighting the cell
+// under the cursor, if it is inside the grid
+func writeANSI(w io.Writer, g *Grid, opts *TermOptions, cursorX, cursorY int) error {
+	palette := opts.Palette
+	if palette == nil {
+		palette = DefaultPalette(int(maxState(g)) + 1)
+	}
+	cursorColor := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	colorOf := func(x, y int) (color.Color, bool) {
+		if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+			return nil, false
+		}
+		if x == cursorX && y == cursorY {
+			return cursorColor, true
+		}
+		s := int(g.GetCell(x, y))
+		if s >= len(palette) {
+			return color.RGBA{0x80, 0x80, 0x80, 0xff}, true
+		}
+		return palette[s], true
+	}
+
+	bw := bufio.NewWriter(w)
+	cols, rows := opts.viewportSize(g)
+	for row := 0; row < rows; row++ {
+		fg, bg := "", ""
+		for col := 0; col < cols; col++ {
+			x, y := opts.X+col, opts.Y+2*row
+			upper, hasUpper := colorOf(x, y)
+			lower, hasLower := colorOf(x, y+1)
+			if !hasUpper && !hasLower {
+				if fg != "" || bg != "" {
+					bw.WriteString("\x1b[0m")
+					fg, bg = "", ""
+				}
+				bw.WriteByte(' ')
+				continue
+			}
+			// The upper cell is drawn with the foreground color, except when
+			// only the lower cell is inside the grid
+			block := "▀"
+			var newFg, newBg string
+			switch {
+			case hasUpper && hasLower:
+				newFg = ansiColor(upper, opts.ColorMode, false)
+				newBg = ansiColor(lower, opts.ColorMode, true)
+			case hasUpper:
+				newFg = ansiColor(upper, opts.ColorMode, false)
+			default:
+				newFg = ansiColor(lower, opts.ColorMode, false)
+				block = "▄"
+			}
+			if (fg != "" && newFg == "") || (bg != "" && newBg == "") {
+				bw.WriteString("\x1b[0m")
+				fg, bg = "", ""
+			}
+			if newFg != fg {
+				bw.WriteString(newFg)
+				fg = newFg
+			}
+			if newBg != bg {
+				bw.WriteString(newBg)
+				bg = newBg
+			}
+			bw.WriteString(block)
+		}
+		bw.WriteString("\x1b[0m\r\n")
+	}
+	return bw.Flush()
+}
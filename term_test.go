@@ -0,0 +1,28 @@
+package cella
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteANSI(t *testing.T) {
+	g := NewGrid(2, 3)
+	g.SetCell(0, 1, 1)
+	var buf bytes.Buffer
+	if err := WriteANSI(&buf, g, TermOptions{ColorMode: ColorModeTrueColor}); err != nil {
+		t.Fatal(err)
+	}
+	black, white := "\x1b[38;2;0;0;0m", "\x1b[48;2;255;255;255m"
+	expected := black + white + "▀" + "\x1b[48;2;0;0;0m▀\x1b[0m\r\n" + black + "▀▀\x1b[0m\r\n"
+	if buf.String() != expected {
+		t.Fatalf("ANSI output does not match: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteANSI(&buf, g, TermOptions{X: 1, Y: 1, Columns: 1, Rows: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "\x1b[38;5;16m\x1b[48;5;16m▀\x1b[0m\r\n" {
+		t.Fatalf("ANSI viewport does not match: %q", buf.String())
+	}
+}
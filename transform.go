@@ -0,0 +1,210 @@
+package cella
+
+// Grid transformations return new grids with the auxiliar borders in state
+// 0, except Clone. Editing operations change the cells of the grid in place
+// and leave the auxiliar borders untouched.
+
+// Fill sets all the cells of the grid to the state
+func (g *Grid) Fill(state Cell) {
+	for _, row := range g.Cells {
+		for x := range row {
+			row[x] = state
+		}
+	}
+}
+
+// FillRect sets the cells of the rectangle to the state.
+// The rectangle is clipped to the grid.
+func (g *Grid) FillRect(x, y, w, h int, state Cell) {
+	x0, y0, x1, y1, ok := g.clip(x, y, w, h)
+	if !ok {
+		return
+	}
+	for cy := y0; cy < y1; cy++ {
+		row := g.Cells[cy][x0:x1]
+		for cx := range row {
+			row[cx] = state
+		}
+	}
+}
+
+// clip returns the corners of the rectangle inside the grid, the last
+// value is false if the rectangle is outside of the grid
+func (g *Grid) clip(x, y, w, h int) (x0, y0, x1, y1 int, ok bool) {
+	x0, y0, x1, y1 = x, y, x+w, y+h
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	if x1 > g.Width {
+		x1 = g.Width
+	}
+	if y1 > g.Height {
+		y1 = g.Height
+	}
+	return x0, y0, x1, y1, x0 < x1 && y0 < y1
+}
+
+// SubGrid returns a new grid of size w x h with the cells of the rectangle
+// starting at (x, y). Cells of the rectangle outside of the grid are in
+// state 0. It returns nil if the size is not valid.
+func (g *Grid) SubGrid(x, y, w, h int) *Grid {
+	s := NewGrid(w, h)
+	if s == nil {
+		return nil
+	}
+	if x0, y0, x1, y1, ok := g.clip(x, y, w, h); ok {
+		for cy := y0; cy < y1; cy++ {
+			copy(s.Cells[cy-y][x0-x:], g.Cells[cy][x0:x1])
+		}
+	}
+	return s
+}
+
+// Crop returns a new grid with the cells of the rectangle clipped to the
+// grid. It returns nil if the rectangle is outside of the grid.
+func (g *Grid) Crop(x, y, w, h int) *Grid {
+	x0, y0, x1, y1, ok := g.clip(x, y, w, h)
+	if !ok {
+		return nil
+	}
+	return g.SubGrid(x0, y0, x1-x0, y1-y0)
+}
+
+// PasteMode is how the cells pasted are combined with the cells of the grid
+type PasteMode uint8
+
+const (
+	PasteCopy        PasteMode = iota // Cells are replaced
+	PasteTransparent                  // Cells are replaced, except by cells in state 0
+	PasteOr                           // Cells are combined with a bitwise OR of their states
+	PasteXor                          // Cells are combined with a bitwise XOR of their states
+)
+
+// Paste pastes the cells of src with its top left corner at (x, y).
+// Cells outside of the grid are ignored.
+func (g *Grid) Paste(src *Grid, x, y int, mode PasteMode) {
+	x0, y0, x1, y1, ok := g.clip(x, y, src.Width, src.Height)
+	if !ok {
+		return
+	}
+	for cy := y0; cy < y1; cy++ {
+		dst := g.Cells[cy][x0:x1]
+		from := src.Cells[cy-y][x0-x:]
+		for cx := range dst {
+			switch mode {
+			case PasteCopy:
+				dst[cx] = from[cx]
+			case PasteTransparent:
+				if from[cx] != 0 {
+					dst[cx] = from[cx]
+				}
+			case PasteOr:
+				dst[cx] |= from[cx]
+			case PasteXor:
+				dst[cx] ^= from[cx]
+			}
+		}
+	}
+}
+
+// Resize returns a new grid of size w x h with the cells of the grid in its
+// top left corner. Cells that do not fit are lost and new cells are in
+// state 0. It returns nil if the size is not valid.
+func (g *Grid) Resize(w, h int) *Grid {
+	return g.SubGrid(0, 0, w, h)
+}
+
+// Rotate90 returns a new grid rotated 90 degrees clockwise
+func (g *Grid) Rotate90() *Grid {
+	r := NewGrid(g.Height, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			r.Cells[x][g.Height-1-y] = g.Cells[y][x]
+		}
+	}
+	return r
+}
+
+// Rotate180 returns a new grid rotated 180 degrees
+func (g *Grid) Rotate180() *Grid {
+	r := NewGrid(g.Width, g.Height)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			r.Cells[g.Height-1-y][g.Width-1-x] = g.Cells[y][x]
+		}
+	}
+	return r
+}
+
+// Rotate270 returns a new grid rotated 90 degrees counterclockwise
+func (g *Grid) Rotate270() *Grid {
+	r := NewGrid(g.Height, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			r.Cells[g.Width-1-x][y] = g.Cells[y][x]
+		}
+	}
+	return r
+}
+
+// FlipHorizontal returns a new grid mirrored from left to right
+func (g *Grid) FlipHorizontal() *Grid {
+	r := NewGrid(g.Width, g.Height)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			r.Cells[y][g.Width-1-x] = g.Cells[y][x]
+		}
+	}
+	return r
+}
+
+// FlipVertical returns a new grid mirrored from top to bottom
+func (g *Grid) FlipVertical() *Grid {
+	r := NewGrid(g.Width, g.Height)
+	for y := 0; y < g.Height; y++ {
+		copy(r.Cells[g.Height-1-y], g.Cells[y])
+	}
+	return r
+}
+
+// Transpose returns a new grid reflected over its main diagonal
+func (g *Grid) Transpose() *Grid {
+	r := NewGrid(g.Height, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			r.Cells[x][y] = g.Cells[y][x]
+		}
+	}
+	return r
+}
+
+// Translate returns a new grid with the cells moved dx columns to the right
+// and dy rows down. If wrap is set the cells moved out of the grid enter by
+// the opposite side, otherwise they are lost and new cells are in state 0.
+func (g *Grid) Translate(dx, dy int, wrap bool) *Grid {
+	r := NewGrid(g.Width, g.Height)
+	if wrap {
+		dx, dy = mod(dx, g.Width), mod(dy, g.Height)
+		for y := 0; y < g.Height; y++ {
+			row := r.Cells[(y+dy)%g.Height]
+			for x := 0; x < g.Width; x++ {
+				row[(x+dx)%g.Width] = g.Cells[y][x]
+			}
+		}
+		return r
+	}
+	r.Paste(g, dx, dy, PasteCopy)
+	return r
+}
+
+// mod returns the non negative remainder of a divided by n
+func mod(a, n int) int {
+	a %= n
+	if a < 0 {
+		a += n
+	}
+	return a
+}
@@ -0,0 +1,85 @@
+package cella
+
+import (
+	"testing"
+)
+
+// gridFromRows creates a grid from rows of digits
+func gridFromRows(rows ...string) *Grid {
+	g := NewGrid(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, ch := range row {
+			g.SetCell(x, y, Cell(ch-'0'))
+		}
+	}
+	return g
+}
+
+func TestTransformations(t *testing.T) {
+	g := gridFromRows(
+		"120",
+		"003",
+	)
+	tests := []struct {
+		name     string
+		got      *Grid
+		expected *Grid
+	}{
+		{"rotate 90", g.Rotate90(), gridFromRows("01", "02", "30")},
+		{"rotate 180", g.Rotate180(), gridFromRows("300", "021")},
+		{"rotate 270", g.Rotate270(), gridFromRows("03", "20", "10")},
+		{"flip horizontal", g.FlipHorizontal(), gridFromRows("021", "300")},
+		{"flip vertical", g.FlipVertical(), gridFromRows("003", "120")},
+		{"transpose", g.Transpose(), gridFromRows("10", "20", "03")},
+		{"translate", g.Translate(1, 1, false), gridFromRows("000", "012")},
+		{"translate wrap", g.Translate(-1, 1, true), gridFromRows("030", "201")},
+		{"subgrid", g.SubGrid(1, -1, 3, 2), gridFromRows("000", "200")},
+		{"crop", g.Crop(1, -1, 3, 2), gridFromRows("20")},
+		{"resize", g.Resize(4, 1), gridFromRows("1200")},
+	}
+	for _, test := range tests {
+		if !EqualsGrid(test.got, test.expected) {
+			d, _ := NewDelta(test.expected, test.got)
+			t.Fatalf("%s does not match: %v", test.name, d)
+		}
+	}
+	if !EqualsGrid(g.Rotate90().Rotate90(), g.Rotate180()) || !EqualsGrid(g.Rotate270().Rotate90(), g) {
+		t.Fatal("Rotations do not compose")
+	}
+	if g.Crop(5, 5, 2, 2) != nil {
+		t.Fatal("Crop outside of the grid")
+	}
+}
+
+func TestEditing(t *testing.T) {
+	g := gridFromRows(
+		"1100",
+		"1100",
+	)
+	src := gridFromRows("01", "11")
+	tests := []struct {
+		mode     PasteMode
+		expected *Grid
+	}{
+		{PasteCopy, gridFromRows("1010", "1111")},
+		{PasteTransparent, gridFromRows("1110", "1111")},
+		{PasteOr, gridFromRows("1110", "1111")},
+		{PasteXor, gridFromRows("1110", "1011")},
+	}
+	for _, test := range tests {
+		dst := g.Clone()
+		dst.Paste(src, 1, 0, test.mode)
+		dst.Paste(src, 3, 0, test.mode)
+		if !EqualsGrid(dst, test.expected) {
+			t.Fatalf("Paste mode %d does not match: %v", test.mode, Diff(test.expected, dst))
+		}
+	}
+	g.FillRect(-1, 1, 3, 5, 2)
+	if !EqualsGrid(g, gridFromRows("1100", "2200")) {
+		t.Fatal("FillRect does not match")
+	}
+	g.Fill(3)
+	if g.GetCell(3, 1) != 3 || g.WholeGrid[0][0] != 0 {
+		t.Fatal("Fill does not match")
+	}
+}
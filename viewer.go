@@ -0,0 +1,306 @@
+package cella
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Viewer is an interactive terminal viewer of a Cella2d.
+//
+// Keys:
+//
+//	space        play or pause
+//	n            step a single generation
+//	+ -          increase or decrease the speed
+//	arrows hjkl  move the cursor, the viewport scrolls to follow it
+//	t enter      toggle the cell under the cursor to the next state
+//	q ctrl-c     quit
+type Viewer struct {
+	Automaton *Cella2d      // Automaton shown
+	Options   TermOptions   // Rendering options, the viewport is updated by the viewer
+	Delay     time.Duration // Delay between generations while playing
+	CursorX   int           // Column of the cursor in the grid
+	CursorY   int           // Row of the cursor in the grid
+	Playing   bool          // Whether generations are calculated continuously
+}
+
+// NewViewer creates a new viewer of the automaton
+func NewViewer(c *Cella2d) *Viewer {
+	v := new(Viewer)
+	v.Automaton = c
+	v.Options.Palette = DefaultPalette(c.NumStates)
+	v.Options.ColorMode = ColorModeTrueColor
+	v.Delay = 100 * time.Millisecond
+	return v
+}
+
+// viewer limits of the delay between generations
+const (
+	minViewerDelay = 10 * time.Millisecond
+	maxViewerDelay = 5 * time.Second
+)
+
+// Step calculates the next generation shown by the viewer
+func (v *Viewer) Step() error {
+	return v.Automaton.Step()
+}
+
+// viewer keys decoded from the input
+const (
+	keyUp = iota + 256
+	keyDown
+	keyLeft
+	keyRight
+)
+
+// readKeys reads keys from the input and sends them to the channel,
+// decoding the escape sequences of the arrow keys.
+// The channel is closed when the input ends, fails or done is closed.
+func readKeys(in io.Reader, keys chan<- int, done <-chan struct{}) {
+	defer close(keys)
+	send := func(key int) bool {
+		select {
+		case keys <- key:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	br := bufio.NewReader(in)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		if b != 0x1b {
+			if !send(int(b)) {
+				return
+			}
+			continue
+		}
+		// Escape sequences of the arrow keys: ESC [ A-D
+		next, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		if next != '[' {
+			continue
+		}
+		b, err = br.ReadByte()
+		if err != nil {
+			return
+		}
+		key := -1
+		switch b {
+		case 'A':
+			key = keyUp
+		case 'B':
+			key = keyDown
+		case 'C':
+			key = keyRight
+		case 'D':
+			key = keyLeft
+		}
+		if key >= 0 && !send(key) {
+			return
+		}
+	}
+}
+
+// deadlineReader is an input whose reads can be cancelled with a deadline
+type deadlineReader interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Run shows the automaton in a terminal of the given size in characters,
+// reading keys from in until the user quits or the input ends.
+// The terminal must be in raw mode for keys to be read without delay.
+// If in has a read deadline, as files that can be polled, the read in
+// progress is cancelled when the viewer quits so later keys are not lost.
+func (v *Viewer) Run(in io.Reader, out io.Writer, columns, rows int) error {
+	keys := make(chan int)
+	done := make(chan struct{})
+	go readKeys(in, keys, done)
+	defer func() {
+		close(done)
+		if d, ok := in.(deadlineReader); ok && d.SetReadDeadline(time.Now()) == nil {
+			for range keys {
+			}
+			d.SetReadDeadline(time.Time{})
+		}
+	}()
+
+	// The last row is used for the status line
+	v.Options.Columns = columns
+	v.Options.Rows = rows - 1
+	if v.Options.Rows < 1 {
+		v.Options.Rows = 1
+	}
+	io.WriteString(out, "\x1b[?25l\x1b[2J")
+	defer io.WriteString(out, "\x1b[0m\x1b[?25h\r\n")
+
+	// Generations keep the counts up to date and toggles update them
+	v.Automaton.CountCellsPerState()
+	// The ticker only changes when playing starts or the delay changes, so
+	// keys do not delay the generations
+	var ticker *time.Ticker
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	delay := v.Delay
+	for {
+		if err := v.draw(out); err != nil {
+			return err
+		}
+		switch {
+		case v.Playing && ticker == nil:
+			ticker = time.NewTicker(v.Delay)
+			delay = v.Delay
+		case v.Playing && delay != v.Delay:
+			ticker.Reset(v.Delay)
+			delay = v.Delay
+		case !v.Playing && ticker != nil:
+			ticker.Stop()
+			ticker = nil
+		}
+		var tick <-chan time.Time
+		if ticker != nil {
+			tick = ticker.C
+		}
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			quit, err := v.handleKey(key)
+			if err != nil || quit {
+				return err
+			}
+		case <-tick:
+			if err := v.Step(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleKey handles a key and returns true if the viewer must quit
+func (v *Viewer) handleKey(key int) (bool, error) {
+	g := v.Automaton.InitGrid
+	switch key {
+	case 'q', 3:
+		return true, nil
+	case ' ':
+		v.Playing = !v.Playing
+	case 'n':
+		v.Playing = false
+		return false, v.Step()
+	case '+', '=':
+		v.Delay /= 2
+		if v.Delay < minViewerDelay {
+			v.Delay = minViewerDelay
+		}
+	case '-', '_':
+		v.Delay *= 2
+		if v.Delay > maxViewerDelay {
+			v.Delay = maxViewerDelay
+		}
+	case keyUp, 'k':
+		if v.CursorY > 0 {
+			v.CursorY--
+		}
+	case keyDown, 'j':
+		if v.CursorY < g.Height-1 {
+			v.CursorY++
+		}
+	case keyLeft, 'h':
+		if v.CursorX > 0 {
+			v.CursorX--
+		}
+	case keyRight, 'l':
+		if v.CursorX < g.Width-1 {
+			v.CursorX++
+		}
+	case 't', '\r', '\n':
+		c := v.Automaton
+		old := g.GetCell(v.CursorX, v.CursorY)
+		state := Cell((int(old) + 1) % c.NumStates)
+		g.SetCell(v.CursorX, v.CursorY, state)
+		if int(old) < len(c.CellsPerState) {
+			c.CellsPerState[old]--
+		}
+		c.CellsPerState[state]++
+	}
+	v.scroll()
+	return false, nil
+}
+
+// scroll moves the viewport so the cursor is visible
+func (v *Viewer) scroll() {
+	o := &v.Options
+	if v.CursorX < o.X {
+		o.X = v.CursorX
+	}
+	if o.Columns > 0 && v.CursorX >= o.X+o.Columns {
+		o.X = v.CursorX - o.Columns + 1
+	}
+	// Each character row shows two cells
+	if v.CursorY < o.Y {
+		o.Y = v.CursorY
+	}
+	if o.Rows > 0 && v.CursorY >= o.Y+2*o.Rows {
+		o.Y = v.CursorY - 2*o.Rows + 1
+	}
+}
+
+// draw writes the viewport and the status line
+func (v *Viewer) draw(out io.Writer) error {
+	c := v.Automaton
+	if _, err := io.WriteString(out, "\x1b[H"); err != nil {
+		return err
+	}
+	if err := writeANSI(out, c.InitGrid, &v.Options, v.CursorX, v.CursorY); err != nil {
+		return err
+	}
+	status := "paused"
+	if v.Playing {
+		status = "playing"
+	}
+	_, err := fmt.Fprintf(out, "\x1b[2Kgen %d  pop %d  (%d,%d)=%d  delay %v  %s",
+		c.Generation, c.Width*c.Height-c.GetCellsPerState()[0], v.CursorX, v.CursorY,
+		c.InitGrid.GetCell(v.CursorX, v.CursorY), v.Delay, status)
+	return err
+}
+
+// RunTerminal runs the viewer on the standard input and output,
+// setting the terminal in raw mode while the viewer runs
+func (v *Viewer) RunTerminal() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("viewer: standard input is not a terminal")
+	}
+	columns, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return fmt.Errorf("viewer: %w", err)
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("viewer: %w", err)
+	}
+	defer term.Restore(fd, state)
+	// The terminal opened again can be polled, so the viewer can cancel the
+	// read in progress when it quits
+	var in io.Reader = os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		in = tty
+	}
+	return v.Run(in, os.Stdout, columns, rows)
+}
@@ -0,0 +1,151 @@
+package cella
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestViewerKeys(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	v := NewViewer(ca)
+	// Draw a blinker with the cursor and step two generations
+	keys := "j\x1b[Bl" + "t" + "l" + "t" + "\x1b[C" + "t" + "nn" + "--" + "q"
+	var out bytes.Buffer
+	if err := v.Run(strings.NewReader(keys), &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ca.Generation != 2 || v.CursorX != 3 || v.CursorY != 2 {
+		t.Fatalf("Viewer generation %d cursor (%d, %d)", ca.Generation, v.CursorX, v.CursorY)
+	}
+	if ca.InitGrid.GetCell(1, 2) != 1 || ca.InitGrid.GetCell(2, 2) != 1 || ca.InitGrid.GetCell(3, 2) != 1 {
+		t.Fatal("Viewer blinker does not match")
+	}
+	if v.Delay != 4*NewViewer(ca).Delay {
+		t.Fatalf("Viewer delay is %v", v.Delay)
+	}
+}
+
+func TestViewerCursorBounds(t *testing.T) {
+	v := NewViewer(newGameOfLife(3, 3))
+	var out bytes.Buffer
+	if err := v.Run(strings.NewReader("kkhh\x1b[A\x1b[Dq"), &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if v.CursorX != 0 || v.CursorY != 0 {
+		t.Fatalf("Cursor moved out of the grid to (%d, %d)", v.CursorX, v.CursorY)
+	}
+	if err := v.Run(strings.NewReader("lllll\x1b[Cjjjjj\x1b[Bq"), &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if v.CursorX != 2 || v.CursorY != 2 {
+		t.Fatalf("Cursor moved out of the grid to (%d, %d)", v.CursorX, v.CursorY)
+	}
+}
+
+func TestViewerScroll(t *testing.T) {
+	v := NewViewer(newGameOfLife(20, 20))
+	var out bytes.Buffer
+	// The viewport is 5 cells wide and 4 cells tall, two cells per row
+	if err := v.Run(strings.NewReader("lllllll"+"jjjjjj"+"q"), &out, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	if v.Options.X != 3 || v.Options.Y != 3 {
+		t.Fatalf("Viewport at (%d, %d) does not follow the cursor", v.Options.X, v.Options.Y)
+	}
+	if err := v.Run(strings.NewReader("hhhhhhh"+"kkkkkk"+"q"), &out, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	if v.Options.X != 0 || v.Options.Y != 0 {
+		t.Fatalf("Viewport at (%d, %d) does not follow the cursor back", v.Options.X, v.Options.Y)
+	}
+}
+
+func TestViewerToggle(t *testing.T) {
+	ca := NewCella2d(3, 3, 3)
+	ca.SetInitGrid(NewGrid(3, 3))
+	ca.SetNextGrid(NewGrid(3, 3))
+	v := NewViewer(ca)
+	var out bytes.Buffer
+	if err := v.Run(strings.NewReader("t\rlq"), &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ca.InitGrid.GetCell(0, 0) != 2 || ca.InitGrid.GetCell(1, 0) != 0 {
+		t.Fatal("Toggled cells do not match")
+	}
+	if ca.CellsPerState[0] != 8 || ca.CellsPerState[2] != 1 {
+		t.Fatalf("Cells per state %v do not follow the toggles", ca.CellsPerState)
+	}
+	if !strings.Contains(out.String(), "gen 0  pop 1  (1,0)=0  delay 100ms  paused") {
+		t.Fatalf("Status line does not match: %q", out.String())
+	}
+	if err := v.Run(strings.NewReader("ttt q"), &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ca.InitGrid.GetCell(1, 0) != 0 || !v.Playing || !strings.Contains(out.String(), "playing") {
+		t.Fatal("Toggling does not cycle through the states or play")
+	}
+}
+
+func TestReadKeysDone(t *testing.T) {
+	keys := make(chan int)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	close(done)
+	go func() {
+		readKeys(strings.NewReader("abc\x1b[A"), keys, done)
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("Key reader did not stop")
+	}
+}
+
+func TestViewerCancelsRead(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	w.Write([]byte("q"))
+	var out bytes.Buffer
+	if err := NewViewer(newGameOfLife(3, 3)).Run(r, &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	// The key after quitting is not taken by the viewer
+	w.Write([]byte("x"))
+	b := make([]byte, 1)
+	r.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := r.Read(b); err != nil || n != 1 || b[0] != 'x' {
+		t.Fatalf("Read after the viewer quit returned %q, %v", b[:n], err)
+	}
+}
+
+func TestViewerPlaysWhileTyping(t *testing.T) {
+	ca := newGameOfLife(5, 5)
+	v := NewViewer(ca)
+	v.Delay = 30 * time.Millisecond
+	v.Playing = true
+	r, w := io.Pipe()
+	go func() {
+		// Keys come faster than the generations
+		for i := 0; i < 20; i++ {
+			w.Write([]byte("h"))
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Write([]byte("q"))
+	}()
+	var out bytes.Buffer
+	if err := v.Run(r, &out, 20, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ca.Generation < 3 {
+		t.Fatalf("Only %d generations while typing", ca.Generation)
+	}
+}